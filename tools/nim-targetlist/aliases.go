@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// osAliases maps alternative OS names to the name nim uses for the target.
+// The hardcoded list carries both macos and macosx; nim itself treats them
+// as the same modern target.
+var osAliases = map[string]string{
+	"macos":  "macosx",
+	"darwin": "macosx",
+	"osx":    "macosx",
+}
+
+// cpuAliases maps historic or foreign CPU names to the name nim uses today.
+var cpuAliases = map[string]string{
+	"x86_64":      "amd64",
+	"x64":         "amd64",
+	"i686":        "i386",
+	"x86":         "i386",
+	"aarch64":     "arm64",
+	"ppc":         "powerpc",
+	"ppc64":       "powerpc64",
+	"ppc64le":     "powerpc64el",
+	"powerpc64le": "powerpc64el",
+	"mipsle":      "mipsel",
+	"mips64le":    "mips64el",
+	"loong64":     "loongarch64",
+	"wasm":        "wasm32",
+}
+
+// resolveAlias follows aliases from name until it reaches a name that is
+// not an alias itself, so chains such as mac=macos, macos=macosx end at
+// macosx. A name on an alias cycle resolves to itself.
+func resolveAlias(name string, aliases map[string]string) string {
+	seen := map[string]bool{name: true}
+	resolved := name
+	for {
+		next, ok := aliases[resolved]
+		if !ok || next == resolved {
+			return resolved
+		}
+		if seen[next] {
+			return name
+		}
+		seen[next] = true
+		resolved = next
+	}
+}
+
+// collapseAliases replaces every alias in set by its canonical name, merging
+// its source and confidence into the canonical entry. It returns the
+// aliases collapsed into each canonical name, sorted.
+func (ts *TargetScanner) collapseAliases(set map[string]string, confidence map[string]float64, aliases map[string]string) map[string][]string {
+	// Decide every rename before touching set: a range over a map may or may
+	// not visit entries added to it during the loop
+	renames := make(map[string]string)
+	for name := range set {
+		if canonical := resolveAlias(name, aliases); canonical != name {
+			renames[name] = canonical
+		}
+	}
+
+	collapsed := make(map[string][]string)
+	for name, canonical := range renames {
+		source := set[name]
+		delete(set, name)
+		ts.mergeSource(set, canonical, source)
+		if c, ok := confidence[name]; ok && c > confidence[canonical] {
+			confidence[canonical] = c
+		}
+		collapsed[canonical] = append(collapsed[canonical], name)
+		if ts.debugMode {
+			log.Printf("Collapsed alias %s into %s", name, canonical)
+		}
+	}
+	for _, names := range collapsed {
+		sort.Strings(names)
+	}
+	return collapsed
+}
+
+// parseOSAliases applies --os-alias values, written alias=name, on top of
+// the built-in osAliases.
+func parseOSAliases(values []string) (map[string]string, error) {
+	aliases := make(map[string]string, len(osAliases)+len(values))
+	for alias, name := range osAliases {
+		aliases[alias] = name
+	}
+	for _, value := range values {
+		alias, name, ok := strings.Cut(strings.ToLower(value), "=")
+		alias, name = strings.TrimSpace(alias), strings.TrimSpace(name)
+		if !ok || alias == "" || name == "" {
+			return nil, fmt.Errorf("%q is not alias=name", value)
+		}
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
+
+// canonicalNames returns names with every alias replaced by its canonical
+// name, so filters given by alias still match.
+func canonicalNames(names []string, aliases map[string]string) []string {
+	if len(names) == 0 {
+		return names
+	}
+	canonical := make([]string, len(names))
+	for i, name := range names {
+		canonical[i] = resolveAlias(name, aliases)
+	}
+	return canonical
+}
+
+// joinAliases combines the aliases of a target's OS and CPU.
+func joinAliases(osNames, cpuNames []string) []string {
+	if len(osNames) == 0 {
+		return cpuNames
+	}
+	return append(append([]string(nil), osNames...), cpuNames...)
+}
+
+// cpuAliasMap returns the known CPU aliases plus those nim printed next to
+// CPU names in its help output.
+func (ts *TargetScanner) cpuAliasMap(cpuSet map[string]string) map[string]string {
+	aliases := make(map[string]string, len(cpuAliases)+len(ts.detectedAliases))
+	for alias, name := range cpuAliases {
+		aliases[alias] = name
+	}
+	for alias, name := range ts.detectedAliases {
+		if _, isCPU := cpuSet[name]; isCPU {
+			aliases[alias] = name
+		}
+	}
+	return aliases
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveAlias(t *testing.T) {
+	aliases := map[string]string{
+		"mac": "macos", "macos": "macosx",
+		"a": "b", "b": "a",
+		"self": "self",
+	}
+	tests := map[string]string{
+		"mac":    "macosx",
+		"macos":  "macosx",
+		"macosx": "macosx",
+		"a":      "a",
+		"self":   "self",
+		"linux":  "linux",
+	}
+	for name, want := range tests {
+		if got := resolveAlias(name, aliases); got != want {
+			t.Errorf("resolveAlias(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCollapseAliasesResolvesChains(t *testing.T) {
+	ts := NewTargetScanner()
+	aliases, err := parseOSAliases([]string{"mac=macos", "apple=mac"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := map[string]string{
+		"apple": "external", "mac": "hardcoded", "macos": "hardcoded",
+		"darwin": "hardcoded", "linux": "detected",
+	}
+	confidence := map[string]float64{"apple": 0.9, "macos": 0.5}
+
+	collapsed := ts.collapseAliases(set, confidence, aliases)
+
+	wantSet := map[string]string{"macosx": "external", "linux": "detected"}
+	if !reflect.DeepEqual(set, wantSet) {
+		t.Errorf("set = %v, want %v", set, wantSet)
+	}
+	if confidence["macosx"] != 0.9 {
+		t.Errorf("macosx confidence %v, want the highest of its aliases", confidence["macosx"])
+	}
+	wantCollapsed := map[string][]string{"macosx": {"apple", "darwin", "mac", "macos"}}
+	if !reflect.DeepEqual(collapsed, wantCollapsed) {
+		t.Errorf("collapsed = %v, want %v", collapsed, wantCollapsed)
+	}
+	if got := canonicalNames([]string{"apple", "linux"}, aliases); !reflect.DeepEqual(got, []string{"macosx", "linux"}) {
+		t.Errorf("canonicalNames() = %v", got)
+	}
+}
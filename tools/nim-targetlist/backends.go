@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// knownBackends are the nim compilation commands probed by detectBackends.
+var knownBackends = []string{"c", "cpp", "objc", "js"}
+
+// detectBackends test-builds a trivial program with each known backend and
+// returns the ones that work on this installation. The C-family backends
+// are built all the way to a binary, since --compileOnly would succeed even
+// without a matching C/C++/Objective-C compiler. The probes run in parallel
+// with the --verify-env environment and --nim-flag flags of verification.
+func (ts *TargetScanner) detectBackends(ctx context.Context) []string {
+	if !ts.nimAvailable {
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp("", "nim-targetlist-backends-")
+	if err != nil {
+		ts.warnf("cannot probe backends: %v", err)
+		return nil
+	}
+	defer os.RemoveAll(tmpDir)
+
+	usable := make([]bool, len(knownBackends))
+	var wg sync.WaitGroup
+	for i, backend := range knownBackends {
+		wg.Add(1)
+		go func(i int, backend string) {
+			defer wg.Done()
+			usable[i] = ts.probeBackend(ctx, backend, tmpDir)
+		}(i, backend)
+	}
+	wg.Wait()
+
+	var supported []string
+	for i, backend := range knownBackends {
+		if usable[i] {
+			supported = append(supported, backend)
+		} else if ts.debugMode {
+			log.Printf("Backend %s is not usable", backend)
+		}
+	}
+	return supported
+}
+
+func (ts *TargetScanner) probeBackend(ctx context.Context, backend, tmpDir string) bool {
+	ctx, cancel := context.WithTimeout(ctx, ts.timeout)
+	defer cancel()
+
+	args := []string{backend, "--hints:off", "--warnings:off"}
+	args = append(args, ts.nimFlags...)
+	args = append(args,
+		"--nimcache:"+filepath.Join(tmpDir, "cache-"+backend),
+		"-o:"+filepath.Join(tmpDir, "probe-"+backend),
+		"-")
+	cmd := exec.CommandContext(ctx, ts.nimBinary, args...)
+	cmd.Stdin = strings.NewReader(verifyProgram)
+	cmd.Env = ts.compileEnv()
+	output, err := cmd.CombinedOutput()
+	ts.noteExecError(ctx, args, err)
+
+	return verificationPassed(output, err)
+}
+
+// representativeBackend picks the nim command that can build a target at
+// all: the js pseudo-targets only build with the js backend and the nimvm
+// ones only exist at compile time, so they are checked rather than built.
+// Everything else uses the C backend.
+func representativeBackend(osName, cpu string) string {
+	switch {
+	case osName == "js" || cpu == "js":
+		return "js"
+	case osName == "nimvm" || cpu == "nimvm" || cpu == "vm":
+		return "check"
+	}
+	return "c"
+}
+
+// backendFor returns the backend to verify a target with: the one given by
+// --backend, or else the target's representative backend.
+func (ts *TargetScanner) backendFor(osName, cpu string) string {
+	if ts.backend != "" {
+		return ts.backend
+	}
+	return representativeBackend(osName, cpu)
+}
+
+func isKnownBackend(name string) bool {
+	for _, backend := range knownBackends {
+		if backend == name {
+			return true
+		}
+	}
+	return false
+}
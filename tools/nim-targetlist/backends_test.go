@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDetectBackendsExcludesRejected(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+if [ "$1" = objc ]; then echo "Error: execution of an external compiler program failed"; exit 1; fi
+`)
+	got := ts.detectBackends(context.Background())
+	if want := []string{"c", "cpp", "js"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("detectBackends() = %v, want %v", got, want)
+	}
+}
+
+func TestDetectBackendsUsesVerifyEnvAndNimFlags(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+[ "$PROBE_ENV" = yes ] || exit 1
+for arg; do [ "$arg" = -d:probe ] && exit 0; done
+exit 1
+`)
+	ts.verifyEnv = []string{"PROBE_ENV=yes"}
+	ts.nimFlags = []string{"-d:probe"}
+	if got := ts.detectBackends(context.Background()); len(got) != len(knownBackends) {
+		t.Errorf("detectBackends() = %v, want all of %v", got, knownBackends)
+	}
+}
+
+func TestDetectBackendsCancelled(t *testing.T) {
+	ts := stubNimScanner(t, "sleep 10\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := ts.detectBackends(ctx); len(got) != 0 {
+		t.Errorf("detectBackends() with a cancelled context = %v, want none", got)
+	}
+}
+
+func TestRepresentativeBackend(t *testing.T) {
+	tests := []struct {
+		os, cpu, want string
+	}{
+		{"linux", "amd64", "c"},
+		{"standalone", "avr", "c"},
+		{"js", "js", "js"},
+		{"linux", "js", "js"},
+		{"js", "amd64", "js"},
+		{"nimvm", "nimvm", "check"},
+		{"linux", "nimvm", "check"},
+		{"standalone", "vm", "check"},
+	}
+	ts := NewTargetScanner()
+	for _, tt := range tests {
+		if got := representativeBackend(tt.os, tt.cpu); got != tt.want {
+			t.Errorf("representativeBackend(%s, %s) = %q, want %q", tt.os, tt.cpu, got, tt.want)
+		}
+		if got := ts.backendFor(tt.os, tt.cpu); got != tt.want {
+			t.Errorf("backendFor(%s, %s) = %q without --backend, want %q", tt.os, tt.cpu, got, tt.want)
+		}
+	}
+
+	ts.backend = "cpp"
+	if got := ts.backendFor("js", "js"); got != "cpp" {
+		t.Errorf("backendFor() = %q with --backend cpp", got)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+const (
+	// backoffWindow is how many recent verifications the failure rate is
+	// measured over.
+	backoffWindow = 32
+	// backoffRate is the failure rate above which the number of parallel
+	// compiles is halved; below half of it, one worker is added back.
+	backoffRate = 0.75
+	// defaultAbortAfter is the default --abort-after-failures. Pruning
+	// already drops the combinations known not to build, so a streak this
+	// long means the toolchain broke rather than the targets being
+	// invalid.
+	defaultAbortAfter = 64
+)
+
+// verifyBackoff throttles the verification workers when compiles start
+// failing en masse, and aborts the run after abortAfter consecutive
+// failures, on the theory that the toolchain broke rather than every
+// target being invalid. Throttling never changes the outcome of a run and
+// can be turned off on its own; aborting is independent of it. A nil
+// backoff neither throttles nor aborts.
+type verifyBackoff struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	max, limit, active int
+	throttle           bool
+	abortAfter         int // 0 never aborts
+
+	recent      []bool // outcomes since the limit was last judged, true for a failure
+	consecutive int
+	aborted     bool
+	lastFailed  string
+}
+
+func newVerifyBackoff(workers, abortAfter int, throttle bool) *verifyBackoff {
+	b := &verifyBackoff{max: workers, limit: workers, throttle: throttle, abortAfter: abortAfter}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire waits until the worker may start a compile. It returns false once
+// the run has been aborted.
+func (b *verifyBackoff) acquire() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.active >= b.limit && !b.aborted {
+		b.cond.Wait()
+	}
+	if b.aborted {
+		return false
+	}
+	b.active++
+	return true
+}
+
+// release records the outcome of a compile started with acquire and
+// adjusts the number of workers allowed to run. Targets that were not
+// compiled after all (status empty) do not count either way.
+func (b *verifyBackoff) release(target, status string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	defer b.cond.Broadcast()
+
+	b.active--
+	if status == "" {
+		return
+	}
+	failed := status == verifyStatusFailed
+	if failed {
+		b.consecutive++
+		b.lastFailed = target
+	} else {
+		b.consecutive = 0
+	}
+	if b.abortAfter > 0 && b.consecutive >= b.abortAfter && !b.aborted {
+		b.aborted = true
+		log.Printf("Aborting verification: the last %d compiles all failed (most recently %s); check that nim and its C compiler still work",
+			b.consecutive, b.lastFailed)
+		return
+	}
+	if !b.throttle {
+		return
+	}
+
+	b.recent = append(b.recent, failed)
+	if len(b.recent) < backoffWindow {
+		return
+	}
+	failures := 0
+	for _, f := range b.recent {
+		if f {
+			failures++
+		}
+	}
+	rate := float64(failures) / float64(len(b.recent))
+	switch {
+	case rate > backoffRate && b.limit > 1:
+		b.limit /= 2
+		log.Printf("%.0f%% of recent verifications failed; reducing to %d parallel compiles", rate*100, b.limit)
+	case rate < backoffRate/2 && b.limit < b.max:
+		b.limit++
+	}
+	// Judge the new limit on fresh results
+	b.recent = b.recent[:0]
+}
+
+// abortedRun reports whether release gave up on the run.
+func (b *verifyBackoff) abortedRun() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.aborted
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// failingTargets returns n distinct targets for the worker pool to verify.
+func failingTargets(n int) []TargetInfo {
+	targets := make([]TargetInfo, n)
+	for i := range targets {
+		targets[i] = TargetInfo{OS: "linux", CPU: fmt.Sprintf("cpu%d", i), Backend: "c"}
+	}
+	return targets
+}
+
+func TestVerifyBackoffThrottlesWithoutAborting(t *testing.T) {
+	b := newVerifyBackoff(8, 0, true)
+	for i := 0; i < 10*backoffWindow; i++ {
+		if !b.acquire() {
+			t.Fatalf("acquire failed after %d failures without an abort threshold", i)
+		}
+		b.release("linux/amd64", verifyStatusFailed)
+	}
+	if b.limit != 1 {
+		t.Errorf("limit = %d after a failure streak, want 1", b.limit)
+	}
+	if b.abortedRun() {
+		t.Error("run aborted without an abort threshold")
+	}
+
+	for i := 0; i < 2*backoffWindow; i++ {
+		b.acquire()
+		b.release("linux/amd64", verifyStatusVerified)
+	}
+	if b.limit != 3 {
+		t.Errorf("limit = %d after %d successes, want 3", b.limit, 2*backoffWindow)
+	}
+}
+
+func TestVerifyBackoffAbortsAfterThreshold(t *testing.T) {
+	b := newVerifyBackoff(4, 10, true)
+	for i := 0; i < 9; i++ {
+		b.acquire()
+		b.release("linux/amd64", verifyStatusFailed)
+	}
+	b.acquire()
+	b.release("linux/amd64", verifyStatusVerified) // resets the streak
+	for i := 0; i < 10; i++ {
+		if !b.acquire() {
+			t.Fatalf("aborted after %d consecutive failures, want 10", i)
+		}
+		b.release("linux/amd64", verifyStatusFailed)
+	}
+	if b.acquire() || !b.abortedRun() {
+		t.Error("run not aborted after 10 consecutive failures")
+	}
+}
+
+// A stub nim that works for a while and then fails every compile, like a
+// toolchain breaking mid-run.
+const breakingNim = `cat >/dev/null
+n=$(($(cat "$0.count" 2>/dev/null || echo 0) + 1))
+echo $n > "$0.count"
+[ $n -le 20 ] || { echo "Error: execution of an external compiler program failed"; exit 1; }
+`
+
+func TestVerifyTargetsNeverAbortsWithZero(t *testing.T) {
+	ts := stubNimScanner(t, breakingNim)
+	ts.verifyAll = true
+	ts.workers = 1
+	ts.abortAfter = 0
+	targets := ts.verifyTargets(context.Background(), failingTargets(200))
+
+	if ts.verifyAborted {
+		t.Error("run aborted with --abort-after-failures 0")
+	}
+	for _, target := range targets {
+		if target.VerifyStatus == "" {
+			t.Fatalf("%s/%s was not verified", target.OS, target.CPU)
+		}
+	}
+}
+
+func TestVerifyTargetsAbortsOnFailureStreak(t *testing.T) {
+	ts := stubNimScanner(t, breakingNim)
+	ts.verifyAll = true
+	ts.workers = 1
+	ts.abortAfter = 30
+	var compiled atomic.Int64
+	ts.OnResult = func(target TargetInfo) {
+		if target.VerifyStatus != "" {
+			compiled.Add(1)
+		}
+	}
+	targets := ts.verifyTargets(context.Background(), failingTargets(200))
+
+	if !ts.verifyAborted {
+		t.Fatal("run not aborted after 30 consecutive failures")
+	}
+	if got := compiled.Load(); got != 50 {
+		t.Errorf("%d targets compiled, want 20 passing and 30 failing", got)
+	}
+	if last := targets[len(targets)-1]; last.SkipReason != "aborted" {
+		t.Errorf("last target SkipReason = %q, want aborted", last.SkipReason)
+	}
+}
+
+func TestVerifyTargetsAbortsByDefault(t *testing.T) {
+	for _, noBackoff := range []bool{false, true} {
+		ts := stubNimScanner(t, breakingNim)
+		ts.verifyAll = true
+		ts.workers = 1
+		ts.noBackoff = noBackoff
+		targets := ts.verifyTargets(context.Background(), failingTargets(200))
+
+		if !ts.verifyAborted {
+			t.Fatalf("noBackoff=%v: run not aborted by the default threshold", noBackoff)
+		}
+		compiled := 0
+		for _, target := range targets {
+			if target.SkipReason != "aborted" {
+				compiled++
+			}
+		}
+		if want := 20 + defaultAbortAfter; compiled != want {
+			t.Errorf("noBackoff=%v: %d targets compiled, want %d", noBackoff, compiled, want)
+		}
+	}
+}
+
+func TestVerifyCommonTargetsAborts(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+echo "Error: execution of an external compiler program failed"; exit 1
+`)
+	ts.abortAfter = 3
+	targets := []TargetInfo{
+		{OS: "linux", CPU: "amd64", Backend: "c"},
+		{OS: "linux", CPU: "arm64", Backend: "c"},
+		{OS: "windows", CPU: "amd64", Backend: "c"},
+		{OS: "windows", CPU: "i386", Backend: "c"},
+		{OS: "macosx", CPU: "arm64", Backend: "c"},
+	}
+	targets = ts.verifyTargets(context.Background(), targets)
+
+	if !ts.verifyAborted {
+		t.Fatal("default verification not aborted after 3 consecutive failures")
+	}
+	for i, target := range targets {
+		aborted := target.SkipReason == "aborted"
+		if aborted != (i >= 3) {
+			t.Errorf("%s/%s: status %q, skip reason %q", target.OS, target.CPU, target.VerifyStatus, target.SkipReason)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// benchmarkTargets is the fixed, representative set compiled by
+// --benchmark-verify, so results are comparable across nim versions.
+var benchmarkTargets = [][2]string{
+	{"linux", "amd64"},
+	{"linux", "arm64"},
+	{"windows", "amd64"},
+	{"macosx", "arm64"},
+	{"freebsd", "amd64"},
+}
+
+// compileStats summarizes the verification compile times of one target.
+type compileStats struct {
+	OS     string
+	CPU    string
+	Runs   int
+	Failed int
+	Mean   time.Duration
+	Median time.Duration
+	P95    time.Duration
+}
+
+// summarizeDurations computes the mean, median and 95th percentile
+// (nearest rank) of durations.
+func summarizeDurations(durations []time.Duration) (mean, median, p95 time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0, 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	mean = total / time.Duration(len(sorted))
+
+	n := len(sorted)
+	if n%2 == 1 {
+		median = sorted[n/2]
+	} else {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	rank := int(math.Ceil(0.95 * float64(n)))
+	p95 = sorted[rank-1]
+	return mean, median, p95
+}
+
+// benchmarkVerify compiles each benchmark target runs times, one compile at
+// a time so they do not compete for the CPU.
+func (ts *TargetScanner) benchmarkVerify(ctx context.Context, runs int) ([]compileStats, error) {
+	if !ts.nimAvailable {
+		return nil, fmt.Errorf("nim command not available")
+	}
+
+	var results []compileStats
+	for _, target := range benchmarkTargets {
+		osName, cpu := target[0], target[1]
+		stats := compileStats{OS: osName, CPU: cpu, Runs: runs}
+
+		durations := make([]time.Duration, 0, runs)
+		for i := 0; i < runs && ctx.Err() == nil; i++ {
+			start := time.Now()
+			_, output, err := ts.runVerify(ctx, osName, cpu, ts.backendFor(osName, cpu))
+			durations = append(durations, time.Since(start))
+			if !verificationPassed(output, err) {
+				stats.Failed++
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		stats.Mean, stats.Median, stats.P95 = summarizeDurations(durations)
+		results = append(results, stats)
+	}
+	return results, nil
+}
+
+func outputBenchmark(w io.Writer, results []compileStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Target\tRuns\tFailed\tMean\tMedian\tP95")
+	fmt.Fprintln(tw, "──────\t────\t──────\t────\t──────\t───")
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s/%s\t%d\t%d\t%s\t%s\t%s\n",
+			r.OS, r.CPU, r.Runs, r.Failed, round(r.Mean), round(r.Median), round(r.P95))
+	}
+	return tw.Flush()
+}
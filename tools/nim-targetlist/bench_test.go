@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeDurations(t *testing.T) {
+	ms := func(ns ...int) []time.Duration {
+		durations := make([]time.Duration, len(ns))
+		for i, n := range ns {
+			durations[i] = time.Duration(n) * time.Millisecond
+		}
+		return durations
+	}
+	tests := []struct {
+		durations         []time.Duration
+		mean, median, p95 time.Duration
+	}{
+		{nil, 0, 0, 0},
+		{ms(7), 7 * time.Millisecond, 7 * time.Millisecond, 7 * time.Millisecond},
+		{ms(30, 10, 20), 20 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond},
+		{ms(40, 10, 30, 20), 25 * time.Millisecond, 25 * time.Millisecond, 40 * time.Millisecond},
+		// Nearest rank: the 19th of 20 values
+		{ms(20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1),
+			10500 * time.Microsecond, 10500 * time.Microsecond, 19 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		mean, median, p95 := summarizeDurations(tt.durations)
+		if mean != tt.mean || median != tt.median || p95 != tt.p95 {
+			t.Errorf("summarizeDurations(%v) = %v, %v, %v, want %v, %v, %v",
+				tt.durations, mean, median, p95, tt.mean, tt.median, tt.p95)
+		}
+	}
+}
+
+func TestBenchmarkVerify(t *testing.T) {
+	// Every compile takes about 50ms; windows never verifies
+	ts, calls := recordingNimScanner(t, `cat >/dev/null
+sleep 0.05
+case "$*" in *--os:windows*) echo "Error: no mingw"; exit 1 ;; esac
+`)
+	results, err := ts.benchmarkVerify(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(benchmarkTargets) || len(calls()) != 3*len(benchmarkTargets) {
+		t.Fatalf("%d results from %d compiles", len(results), len(calls()))
+	}
+	for i, r := range results {
+		if r.OS != benchmarkTargets[i][0] || r.CPU != benchmarkTargets[i][1] || r.Runs != 3 {
+			t.Errorf("result %d is %+v", i, r)
+		}
+		wantFailed := 0
+		if r.OS == "windows" {
+			wantFailed = 3
+		}
+		if r.Failed != wantFailed {
+			t.Errorf("%s/%s: %d failed, want %d", r.OS, r.CPU, r.Failed, wantFailed)
+		}
+		if r.Mean < 50*time.Millisecond || r.Median < 50*time.Millisecond || r.P95 < r.Median {
+			t.Errorf("%s/%s: implausible timings %+v", r.OS, r.CPU, r)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := outputBenchmark(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2+len(results) || strings.Join(strings.Fields(lines[4])[:3], " ") != "windows/amd64 3 3" {
+		t.Errorf("benchmark table:\n%s", buf.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ts.benchmarkVerify(ctx, 3); err != context.Canceled {
+		t.Errorf("interrupted benchmark: error %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// osBudget tracks how much compile time each OS has consumed so that one
+// slow OS cannot starve the others in a bounded verification run.
+type osBudget struct {
+	mu    sync.Mutex
+	limit time.Duration
+	spent map[string]time.Duration
+}
+
+func newOSBudget(limit time.Duration) *osBudget {
+	return &osBudget{limit: limit, spent: make(map[string]time.Duration)}
+}
+
+// exhausted reports whether osName has used up its budget. A nil budget is
+// never exhausted.
+func (b *osBudget) exhausted(osName string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent[osName] >= b.limit
+}
+
+func (b *osBudget) charge(osName string, d time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent[osName] += d
+}
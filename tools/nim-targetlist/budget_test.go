@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOSBudget(t *testing.T) {
+	var none *osBudget
+	none.charge("linux", time.Hour)
+	if none.exhausted("linux") {
+		t.Error("a nil budget ran out")
+	}
+
+	b := newOSBudget(time.Second)
+	b.charge("linux", 600*time.Millisecond)
+	if b.exhausted("linux") {
+		t.Error("linux ran out after 600ms of 1s")
+	}
+	b.charge("linux", 400*time.Millisecond)
+	if !b.exhausted("linux") {
+		t.Error("linux did not run out after 1s of 1s")
+	}
+	if b.exhausted("windows") {
+		t.Error("linux's compiles were charged to windows")
+	}
+}
+
+func TestPerOSDeadlineSkipsSlowOS(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+case "$*" in *--os:windows*) sleep 0.2 ;; esac
+`)
+	ts.perOSDeadline = 100 * time.Millisecond
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: "windows", CPU: "amd64", Backend: "c"},
+		{OS: "linux", CPU: "amd64", Backend: "c"},
+		{OS: "windows", CPU: "i386", Backend: "c"},
+		{OS: "linux", CPU: "arm64", Backend: "c"},
+	})
+
+	want := []struct{ status, reason string }{
+		{verifyStatusVerified, ""},
+		{verifyStatusVerified, ""},
+		{verifyStatusSkipped, "budget_skipped"},
+		{verifyStatusVerified, ""},
+	}
+	for i, target := range targets {
+		if target.VerifyStatus != want[i].status || target.SkipReason != want[i].reason {
+			t.Errorf("%s/%s: status %q, skip reason %q, want %q, %q", target.OS, target.CPU,
+				target.VerifyStatus, target.SkipReason, want[i].status, want[i].reason)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a stored verification outcome.
+type cacheEntry struct {
+	Verified   bool      `json:"verified"`
+	Deprecated bool      `json:"deprecated,omitempty"`
+	FailReason string    `json:"fail_reason,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// verifyCache persists verification outcomes between runs. Entries are
+// keyed by the nim version, the full verification argv, the test program
+// and the --verify-env settings, so upgrading nim, changing flags or
+// switching cross toolchains never reuses a stale result.
+type verifyCache struct {
+	path string
+	ttl  time.Duration
+
+	// readOnly serves hits but never writes the file, for caches shared
+	// from a read-only mount
+	readOnly bool
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+	// stored counts the results put this run
+	stored int
+}
+
+// defaultCachePath returns ~/.cache/nim-targetlist/verify.json, or the
+// platform's equivalent.
+func defaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, programName, "verify.json"), nil
+}
+
+// loadVerifyCache reads the cache at path. A missing file is an empty
+// cache.
+func loadVerifyCache(path string, ttl time.Duration) (*verifyCache, error) {
+	cache := &verifyCache{path: path, ttl: ttl, entries: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// saveCache writes back the verification cache, if one is in use. Failing
+// to save is only worth a warning: the results themselves are fine.
+func (ts *TargetScanner) saveCache() {
+	if ts.cache == nil {
+		return
+	}
+	if err := ts.cache.save(); err != nil {
+		ts.warnf("cannot save verification cache: %v", err)
+	}
+}
+
+func cacheKey(nimVersion string, args []string, program string, env []string) string {
+	sum := sha256.Sum256([]byte(program))
+	key := nimVersion + " " + strings.Join(args, " ") + " " + hex.EncodeToString(sum[:8])
+	if len(env) > 0 {
+		sorted := append([]string(nil), env...)
+		sort.Strings(sorted)
+		key += " env:" + strings.Join(sorted, " ")
+	}
+	return key
+}
+
+// get returns the stored result for key if it has not expired.
+func (c *verifyCache) get(key string) (verifyResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || (c.ttl > 0 && time.Since(entry.CheckedAt) > c.ttl) {
+		return verifyResult{}, false
+	}
+	return verifyResult{verified: entry.Verified, deprecated: entry.Deprecated, failReason: entry.FailReason}, true
+}
+
+func (c *verifyCache) put(key string, result verifyResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = cacheEntry{
+		Verified:   result.verified,
+		Deprecated: result.deprecated,
+		FailReason: result.failReason,
+		CheckedAt:  time.Now().UTC(),
+	}
+	c.dirty = true
+	c.stored++
+}
+
+// save writes the cache back if anything changed, dropping expired
+// entries. The file is replaced atomically so a concurrent run never reads
+// a partial cache.
+func (c *verifyCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.readOnly || !c.dirty {
+		return nil
+	}
+	for key, entry := range c.entries {
+		if c.ttl > 0 && time.Since(entry.CheckedAt) > c.ttl {
+			delete(c.entries, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".verify-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// outputCacheWarm writes the one-line summary of --format cache-warm, which
+// runs verification only to fill the cache for later runs.
+func outputCacheWarm(w io.Writer, c *verifyCache) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprintf(w, "Cached %d verification results in %s (%d entries)\n", c.stored, c.path, len(c.entries))
+	return err
+}
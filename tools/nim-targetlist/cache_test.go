@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheKeyIncludesVerifyEnv(t *testing.T) {
+	args := []string{"--os:linux", "--cpu:arm", "c", "-"}
+	plain := cacheKey("2.0.2", args, verifyProgram, nil)
+	gcc := cacheKey("2.0.2", args, verifyProgram, []string{"CC=arm-linux-gnueabihf-gcc", "AR=ar"})
+	clang := cacheKey("2.0.2", args, verifyProgram, []string{"CC=clang"})
+
+	if plain == gcc || gcc == clang {
+		t.Errorf("keys do not depend on --verify-env: %q, %q, %q", plain, gcc, clang)
+	}
+	if reordered := cacheKey("2.0.2", args, verifyProgram, []string{"AR=ar", "CC=arm-linux-gnueabihf-gcc"}); reordered != gcc {
+		t.Errorf("key depends on --verify-env order: %q != %q", reordered, gcc)
+	}
+}
+
+func TestVerifyTargetCachesOnlyConclusiveResults(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+for arg; do
+	case "$arg" in
+	--os:dos) echo "Error: unsupported"; exit 1;;
+	--os:haiku) exec sleep 5;;
+	esac
+done
+`)
+	ts.nimVersion = "2.0.2"
+	ts.timeout = 200 * time.Millisecond
+	ts.cache = &verifyCache{entries: make(map[string]cacheEntry)}
+
+	for _, osName := range []string{"linux", "dos", "haiku"} {
+		ts.verifyTarget(context.Background(), osName, "amd64", "c")
+	}
+
+	cached := func(osName string) bool {
+		key := cacheKey(ts.nimVersion, ts.verifyArgs(osName, "amd64", "c"), verifyProgram, nil)
+		_, ok := ts.cache.get(key)
+		return ok
+	}
+	if !cached("linux") || !cached("dos") {
+		t.Error("completed compiles were not cached")
+	}
+	if cached("haiku") {
+		t.Error("a timed-out compile was cached")
+	}
+}
+
+func TestOutputCacheWarm(t *testing.T) {
+	ts := stubNimScanner(t, "cat >/dev/null\n")
+	ts.nimVersion = "2.0.2"
+	ts.verifyAll = true
+	path := filepath.Join(t.TempDir(), "verify.json")
+	cache, err := loadVerifyCache(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.cache = cache
+
+	targets := []TargetInfo{
+		{OS: "linux", CPU: "amd64", Backend: "c"},
+		{OS: "windows", CPU: "i386", Backend: "c"},
+		{OS: "netbsd", CPU: "riscv64", Backend: "c"},
+	}
+	ts.verifyTargets(context.Background(), targets)
+	ts.saveCache()
+
+	var out bytes.Buffer
+	if err := outputCacheWarm(&out, ts.cache); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Cached 3 verification results in " + path + " (3 entries)\n"; out.String() != want {
+		t.Errorf("output %q, want %q", out.String(), want)
+	}
+	if strings.Count(out.String(), "\n") != 1 {
+		t.Errorf("cache-warm printed more than one line: %q", out.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cache not written: %v", err)
+	}
+	var entries map[string]cacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("cache file has %d entries, want 3", len(entries))
+	}
+}
+
+func TestReadOnlyCacheServesHitsWithoutWriting(t *testing.T) {
+	ts, calls := recordingNimScanner(t, "cat >/dev/null\n")
+	ts.nimVersion = "2.0.2"
+	path := filepath.Join(t.TempDir(), "verify.json")
+
+	// A writable run fills the cache with linux/amd64
+	cache, err := loadVerifyCache(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.cache = cache
+	ts.verifyTarget(context.Background(), "linux", "amd64", "c")
+	ts.saveCache()
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cache, err = loadVerifyCache(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	cache.readOnly = true
+	ts.cache = cache
+	if !ts.verifyTarget(context.Background(), "linux", "amd64", "c").verified {
+		t.Error("cached result not served")
+	}
+	if n := len(calls()); n != 1 {
+		t.Errorf("nim ran %d times, want once: the read-only cache should have answered", n)
+	}
+	ts.verifyTarget(context.Background(), "windows", "amd64", "c")
+	ts.saveCache()
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("read-only cache was written:\nbefore %s\nafter %s", before, after)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".verify-*")); len(matches) != 0 {
+		t.Errorf("read-only cache left temporary files: %v", matches)
+	}
+}
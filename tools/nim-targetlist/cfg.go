@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// targetConfig renders the minimal nim.cfg selecting a target: its --os and
+// --cpu, plus the flags the target was verified with (--threads:off for
+// threadless targets and any --triple flags).
+func (ts *TargetScanner) targetConfig(target TargetInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by %s for %s/%s\n", programName, target.OS, target.CPU)
+	fmt.Fprintf(&b, "--os:%s\n", target.OS)
+	fmt.Fprintf(&b, "--cpu:%s\n", target.CPU)
+	if ts.needsThreadsOff(target.OS, target.CPU) {
+		b.WriteString("--threads:off\n")
+	}
+	for _, arg := range ts.tripleFlags[target.OS+"/"+target.CPU] {
+		b.WriteString(arg + "\n")
+	}
+	return b.String()
+}
+
+// emitTargetConfigs writes <os>_<cpu>.nim.cfg into dir for every verified
+// target and returns how many files were written.
+func (ts *TargetScanner) emitTargetConfigs(dir string, targets []TargetInfo) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, target := range targets {
+		if !target.Verified {
+			continue
+		}
+		path := filepath.Join(dir, target.OS+"_"+target.CPU+".nim.cfg")
+		if err := os.WriteFile(path, []byte(ts.targetConfig(target)), 0o644); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
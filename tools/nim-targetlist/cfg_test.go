@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEmitTargetConfigs(t *testing.T) {
+	ts := NewTargetScanner()
+	ts.defaultThreads = true
+	ts.tripleFlags = map[string][]string{"linux/arm": {"--gcc.exe:arm-linux-gnueabihf-gcc", "-d:armv7"}}
+	dir := filepath.Join(t.TempDir(), "cfg")
+	written, err := ts.emitTargetConfigs(dir, []TargetInfo{
+		{OS: "linux", CPU: "arm", Verified: true},
+		{OS: "standalone", CPU: "avr", Verified: true},
+		{OS: "windows", CPU: "i386"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != 2 {
+		t.Errorf("wrote %d configs, want 2", written)
+	}
+
+	want := map[string]string{
+		"linux_arm.nim.cfg": "# Generated by nim-targetlist for linux/arm\n--os:linux\n--cpu:arm\n" +
+			"--gcc.exe:arm-linux-gnueabihf-gcc\n-d:armv7\n",
+		"standalone_avr.nim.cfg": "# Generated by nim-targetlist for standalone/avr\n--os:standalone\n--cpu:avr\n" +
+			"--threads:off\n",
+	}
+	got := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[entry.Name()] = string(data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configs %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// BackendDivergence is a target that verified under some of the compared
+// backends but not all of them.
+type BackendDivergence struct {
+	OS       string          `json:"os"`
+	CPU      string          `json:"cpu"`
+	Verified map[string]bool `json:"verified"`
+}
+
+type BackendComparison struct {
+	Backends       []string            `json:"backends"`
+	Divergent      []BackendDivergence `json:"divergent"`
+	DivergentCount int                 `json:"divergent_count"`
+	TargetCount    int                 `json:"target_count"`
+}
+
+// parseCompareBackends parses the --compare-backends value: exactly two
+// distinct known backends, e.g. "c,cpp".
+func parseCompareBackends(value string) ([]string, error) {
+	backends := strings.Split(value, ",")
+	if len(backends) != 2 {
+		return nil, fmt.Errorf("want exactly two backends, e.g. c,cpp")
+	}
+	for i, backend := range backends {
+		backend = strings.ToLower(strings.TrimSpace(backend))
+		if !isKnownBackend(backend) {
+			return nil, fmt.Errorf("unknown backend %q (known: %s)", backend, strings.Join(knownBackends, ", "))
+		}
+		backends[i] = backend
+	}
+	if backends[0] == backends[1] {
+		return nil, fmt.Errorf("backends must differ")
+	}
+	return backends, nil
+}
+
+// compareBackends verifies the targets once per backend and collects those
+// whose outcome differs. Targets skipped under either backend are not
+// compared, since their outcome is unknown. The verified targets of each
+// run are returned as well, in the order of backends.
+func (ts *TargetScanner) compareBackends(ctx context.Context, targets []TargetInfo, backends []string) (BackendComparison, [][]TargetInfo) {
+	results := make([][]TargetInfo, len(backends))
+	for i, backend := range backends {
+		run := make([]TargetInfo, len(targets))
+		copy(run, targets)
+		for j := range run {
+			run[j].Backend = backend
+			run[j].Command = ts.targetCommand(backend, run[j].OS, run[j].CPU)
+		}
+		results[i] = ts.verifyTargets(ctx, run)
+	}
+
+	comparison := BackendComparison{
+		Backends:    backends,
+		Divergent:   []BackendDivergence{},
+		TargetCount: len(targets),
+	}
+	for j := range targets {
+		verified := make(map[string]bool, len(backends))
+		skipped, agree := false, true
+		for i, backend := range backends {
+			target := results[i][j]
+			skipped = skipped || target.SkipReason != ""
+			verified[backend] = target.Verified
+			agree = agree && target.Verified == results[0][j].Verified
+		}
+		if skipped || agree {
+			continue
+		}
+		comparison.Divergent = append(comparison.Divergent, BackendDivergence{
+			OS:       targets[j].OS,
+			CPU:      targets[j].CPU,
+			Verified: verified,
+		})
+	}
+	comparison.DivergentCount = len(comparison.Divergent)
+	return comparison, results
+}
+
+func outputBackendComparison(w io.Writer, comparison BackendComparison, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(comparison)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(append([]string{"os", "cpu"}, comparison.Backends...)); err != nil {
+			return err
+		}
+		for _, d := range comparison.Divergent {
+			record := []string{d.OS, d.CPU}
+			for _, backend := range comparison.Backends {
+				record = append(record, strconv.FormatBool(d.Verified[backend]))
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "OS\tCPU\t%s\n", strings.ToUpper(strings.Join(comparison.Backends, "\t")))
+		fmt.Fprintln(tw, "──\t───\t"+strings.TrimSuffix(strings.Repeat("────────\t", len(comparison.Backends)), "\t"))
+		for _, d := range comparison.Divergent {
+			mark := make([]string, len(comparison.Backends))
+			for i, backend := range comparison.Backends {
+				mark[i] = "✗"
+				if d.Verified[backend] {
+					mark[i] = "✓"
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", d.OS, d.CPU, strings.Join(mark, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "\n%d of %d targets differ between %s\n",
+			comparison.DivergentCount, comparison.TargetCount, strings.Join(comparison.Backends, " and "))
+		return err
+	default:
+		return fmt.Errorf("--compare-backends does not support format %q", format)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCompareBackendsReportsDivergence(t *testing.T) {
+	// cpp cannot build for freebsd; everything else works with both
+	ts := stubNimScanner(t, `cat >/dev/null
+case " $* " in *" --os:freebsd "*" cpp "*) echo "Error: cpp backend broken"; exit 1;; esac
+`)
+	ts.verifyAll = true
+	targets := []TargetInfo{{OS: "linux", CPU: "amd64"}, {OS: "freebsd", CPU: "amd64"}}
+
+	comparison, runs := ts.compareBackends(context.Background(), targets, []string{"c", "cpp"})
+	want := []BackendDivergence{{OS: "freebsd", CPU: "amd64", Verified: map[string]bool{"c": true, "cpp": false}}}
+	if !reflect.DeepEqual(comparison.Divergent, want) {
+		t.Errorf("Divergent = %+v, want %+v", comparison.Divergent, want)
+	}
+
+	// A required target has to verify under both backends
+	required := [][2]string{{"linux", "amd64"}, {"freebsd", "amd64"}}
+	if missing := missingRequiredInAll(runs, required); !reflect.DeepEqual(missing, []string{"freebsd:amd64"}) {
+		t.Errorf("missingRequiredInAll() = %v, want [freebsd:amd64]", missing)
+	}
+}
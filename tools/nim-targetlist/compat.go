@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// cpuCompatibleOSes restricts CPUs that only make sense with particular
+// OSes. CPUs not listed pair with any OS.
+var cpuCompatibleOSes = map[string][]string{
+	"js":     {"js"},
+	"vm":     {"nimvm"},
+	"nimvm":  {"nimvm"},
+	"wasm32": {"linux", "standalone", "any"},
+	"avr":    {"standalone", "any"},
+	"msp430": {"standalone", "any"},
+	"esp":    {"freertos", "standalone", "any"},
+}
+
+// osCompatibleCPUs restricts OSes that only make sense with particular
+// CPUs. OSes not listed pair with any CPU.
+var osCompatibleCPUs = map[string][]string{
+	"js":    {"js"},
+	"nimvm": {"vm", "nimvm"},
+}
+
+// CompatTable is the set of pairing restrictions consulted when OSes and
+// CPUs are combined; pairs it rules out are pruned before verification.
+// It is written and read as JSON, e.g.
+// {"cpu": {"wasm32": ["linux", "standalone"]}, "os": {"js": ["js"]}}.
+type CompatTable struct {
+	CPU map[string][]string `json:"cpu"` // cpu -> the OSes it pairs with
+	OS  map[string][]string `json:"os"`  // os -> the CPUs it pairs with
+}
+
+// defaultCompatTable returns a copy of the built-in restrictions.
+func defaultCompatTable() *CompatTable {
+	t := &CompatTable{CPU: make(map[string][]string), OS: make(map[string][]string)}
+	for cpu, oses := range cpuCompatibleOSes {
+		t.CPU[cpu] = oses
+	}
+	for osName, cpus := range osCompatibleCPUs {
+		t.OS[osName] = cpus
+	}
+	return t
+}
+
+// loadCompatTable reads restrictions from path on top of the built-in ones.
+// An entry replaces the built-in entry for the same name, and an empty list
+// lifts the restriction altogether.
+func loadCompatTable(path string) (*CompatTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var overrides CompatTable
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid compatibility table %s: %v", path, err)
+	}
+
+	t := defaultCompatTable()
+	overlay := func(table, entries map[string][]string) {
+		for name, names := range entries {
+			if len(names) == 0 {
+				delete(table, name)
+			} else {
+				table[name] = names
+			}
+		}
+	}
+	overlay(t.CPU, overrides.CPU)
+	overlay(t.OS, overrides.OS)
+	return t, nil
+}
+
+// compatible reports whether nim could ever build for osName/cpu according
+// to the table.
+func (t *CompatTable) compatible(osName, cpu string) bool {
+	allowed := func(table map[string][]string, key, name string) bool {
+		names, restricted := table[key]
+		if !restricted {
+			return true
+		}
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+	return allowed(t.CPU, cpu, osName) && allowed(t.OS, osName, cpu)
+}
+
+// write prints the table in the format loadCompatTable accepts.
+func (t *CompatTable) write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(t)
+}
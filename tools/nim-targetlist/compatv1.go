@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// targetInfoV1 and targetsResultV1 are the JSON document as first
+// published. --output-compat-v1 emits exactly these fields so consumers
+// written against it keep working while fields are added to TargetInfo
+// and TargetsResult. Do not add fields here.
+type targetInfoV1 struct {
+	OS       string `json:"os"`
+	CPU      string `json:"cpu"`
+	Verified bool   `json:"verified"`
+	Source   string `json:"source"`
+	Command  string `json:"command"`
+}
+
+type targetsResultV1 struct {
+	Targets         []targetInfoV1 `json:"targets"`
+	TotalCount      int            `json:"total_count"`
+	VerifiedCount   int            `json:"verified_count"`
+	DetectedCount   int            `json:"detected_count"`
+	HardcodedCount  int            `json:"hardcoded_count"`
+	GeneratedAt     string         `json:"generated_at"`
+	VerificationRun bool           `json:"verification_run"`
+	NimAvailable    bool           `json:"nim_available"`
+}
+
+func newTargetsResultV1(result TargetsResult) targetsResultV1 {
+	v1 := targetsResultV1{
+		Targets:         make([]targetInfoV1, len(result.Targets)),
+		TotalCount:      result.TotalCount,
+		VerifiedCount:   result.VerifiedCount,
+		DetectedCount:   result.DetectedCount,
+		HardcodedCount:  result.HardcodedCount,
+		GeneratedAt:     result.GeneratedAt,
+		VerificationRun: result.VerificationRun,
+		NimAvailable:    result.NimAvailable,
+	}
+	for i, target := range result.Targets {
+		v1.Targets[i] = targetInfoV1{
+			OS:       target.OS,
+			CPU:      target.CPU,
+			Verified: target.Verified,
+			Source:   target.Source,
+			Command:  commandV1(target),
+		}
+	}
+	return v1
+}
+
+// commandV1 returns the target's command in its v1 form, which names no
+// backend: "nim --os:linux --cpu:amd64" rather than "nim c --os:...".
+func commandV1(target TargetInfo) string {
+	prefix := "nim " + target.Backend + " "
+	if target.Backend == "" || !strings.HasPrefix(target.Command, prefix) {
+		return target.Command
+	}
+	return "nim " + strings.TrimPrefix(target.Command, prefix)
+}
+
+// outputJSONCompatV1 is outputJSON restricted to the v1 field set.
+func outputJSONCompatV1(w io.Writer, targets []TargetInfo, scanner *TargetScanner, wrapKey string) error {
+	result := newTargetsResultV1(newTargetsResult(targets, scanner))
+
+	var doc interface{} = result
+	if wrapKey != "" {
+		doc = map[string]targetsResultV1{wrapKey: result}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestOutputJSONCompatV1CommandHasNoBackend(t *testing.T) {
+	scanner := NewTargetScanner()
+	scanner.nimFlags = []string{"-d:release"}
+	var targets []TargetInfo
+	for _, spec := range [][2]string{{"linux", "amd64"}, {"js", "js"}, {"nimvm", "amd64"}} {
+		backend := scanner.backendFor(spec[0], spec[1])
+		targets = append(targets, TargetInfo{
+			OS:      spec[0],
+			CPU:     spec[1],
+			Backend: backend,
+			Command: scanner.targetCommand(backend, spec[0], spec[1]),
+		})
+	}
+	// Redacted targets have no command at all
+	targets = append(targets, TargetInfo{OS: "windows", CPU: "i386", Backend: "c"})
+
+	var buf bytes.Buffer
+	if err := outputJSONCompatV1(&buf, targets, scanner, ""); err != nil {
+		t.Fatal(err)
+	}
+	var result targetsResultV1
+	if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"nim --os:linux --cpu:amd64 -d:release",
+		"nim --os:js --cpu:js -d:release",
+		"nim --os:nimvm --cpu:amd64 -d:release",
+		"",
+	}
+	for i, target := range result.Targets {
+		if target.Command != want[i] {
+			t.Errorf("%s/%s: command %q, want %q", target.OS, target.CPU, target.Command, want[i])
+		}
+	}
+}
+
+func TestOutputJSONCompatV1EmitsOnlyV1Fields(t *testing.T) {
+	scanner := NewTargetScanner()
+	scanner.nimAvailable = true
+	scanner.nimVersion = "2.0.2"
+	scanner.backends = []string{"c", "js"}
+	scanner.defaultThreads = true
+	var buf bytes.Buffer
+	if err := outputJSONCompatV1(&buf, []TargetInfo{populatedTarget()}, scanner, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	var targets []map[string]json.RawMessage
+	if err := json.Unmarshal(doc["targets"], &targets); err != nil || len(targets) != 1 {
+		t.Fatalf("targets %s: %v", doc["targets"], err)
+	}
+
+	want := []string{"detected_count", "generated_at", "hardcoded_count", "nim_available",
+		"targets", "total_count", "verification_run", "verified_count"}
+	if got := sortedKeys(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("result fields %v, want %v", got, want)
+	}
+	want = []string{"command", "cpu", "os", "source", "verified"}
+	if got := sortedKeys(targets[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("target fields %v, want %v", got, want)
+	}
+}
+
+func sortedKeys(object map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const programName = "nim-targetlist"
+
+// subcommands lists the positional commands understood by main.
+var subcommands = []string{"completion", "validate", "graphql-schema"}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+}
+
+// completionFlags collects the registered command-line flags in name order.
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		isBool := false
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			isBool = bf.IsBoolFlag()
+		}
+		flags = append(flags, completionFlag{name: f.Name, usage: f.Usage, isBool: isBool})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+// flagValueChoices returns the fixed set of values a flag accepts, if any.
+func flagValueChoices(name string) []string {
+	switch name {
+	case "format":
+		return outputFormats
+	case "group-by":
+		return groupByKeys
+	case "order":
+		return targetOrders
+	case "backend", "compare-backends":
+		return knownBackends
+	case "os-family":
+		return osFamilyNames()
+	case "only-source":
+		return targetSources
+	}
+	return nil
+}
+
+func writeCompletion(w io.Writer, shell string, fs *flag.FlagSet) error {
+	flags := completionFlags(fs)
+	switch shell {
+	case "bash":
+		return writeBashCompletion(w, flags)
+	case "zsh":
+		return writeZshCompletion(w, flags)
+	case "fish":
+		return writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("unsupported shell %q (want one of: %s)", shell, strings.Join(completionShells, ", "))
+	}
+}
+
+func writeBashCompletion(w io.Writer, flags []completionFlag) error {
+	var names []string
+	for _, f := range flags {
+		names = append(names, "--"+f.name)
+	}
+	fn := "_" + strings.ReplaceAll(programName, "-", "_")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", programName)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur prev\n")
+	b.WriteString("    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n\n")
+	b.WriteString("    case \"$prev\" in\n")
+	for _, f := range flags {
+		if choices := flagValueChoices(f.name); choices != nil {
+			fmt.Fprintf(&b, "        --%s|-%s)\n", f.name, f.name)
+			fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(choices, " "))
+			b.WriteString("            return ;;\n")
+		}
+	}
+	b.WriteString("        completion)\n")
+	fmt.Fprintf(&b, "            COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(completionShells, " "))
+	b.WriteString("            return ;;\n")
+	b.WriteString("    esac\n\n")
+	b.WriteString("    if [[ \"$cur\" != -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(subcommands, " "))
+	b.WriteString("        return\n")
+	b.WriteString("    fi\n")
+	fmt.Fprintf(&b, "    COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(names, " "))
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, programName)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshEscape makes a usage string safe inside an _arguments spec.
+func zshEscape(s string) string {
+	r := strings.NewReplacer("[", "(", "]", ")", ":", "\\:", "'", "'\\''")
+	return r.Replace(s)
+}
+
+func writeZshCompletion(w io.Writer, flags []completionFlag) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", programName)
+	fmt.Fprintf(&b, "_arguments \\\n")
+	for _, f := range flags {
+		spec := fmt.Sprintf("--%s[%s]", f.name, zshEscape(f.usage))
+		if !f.isBool {
+			if choices := flagValueChoices(f.name); choices != nil {
+				spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(choices, " "))
+			} else {
+				spec += fmt.Sprintf(":%s:", f.name)
+			}
+		}
+		fmt.Fprintf(&b, "    '%s' \\\n", spec)
+	}
+	fmt.Fprintf(&b, "    '1:command:(%s)' \\\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(&b, "    '2:shell:(%s)'\n", strings.Join(completionShells, " "))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeFishCompletion(w io.Writer, flags []completionFlag) error {
+	quote := func(s string) string {
+		return "'" + strings.ReplaceAll(strings.ReplaceAll(s, "\\", "\\\\"), "'", "\\'") + "'"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", programName)
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -l %s -d %s", programName, f.name, quote(f.usage))
+		if !f.isBool {
+			if choices := flagValueChoices(f.name); choices != nil {
+				line += fmt.Sprintf(" -x -a %s", quote(strings.Join(choices, " ")))
+			} else {
+				line += " -r"
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_use_subcommand' -f -a %s\n", programName, quote(strings.Join(subcommands, " ")))
+	fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from completion' -f -a %s\n", programName, quote(strings.Join(completionShells, " ")))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkShellSyntax parses script with the shell's no-exec mode, skipping
+// when the shell is not installed.
+func checkShellSyntax(t *testing.T, shell, script string) {
+	t.Helper()
+	path, err := exec.LookPath(shell)
+	if err != nil {
+		t.Skipf("%s not installed", shell)
+	}
+	file := filepath.Join(t.TempDir(), "completion."+shell)
+	if err := os.WriteFile(file, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(path, "-n", file).CombinedOutput(); err != nil {
+		t.Errorf("%s rejects the completion script: %v\n%s\n%s", shell, err, out, script)
+	}
+}
+
+func TestCompletionIsValidForEachShell(t *testing.T) {
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			stdout, stderr, code := runMain(t, "", "completion", shell)
+			if code != 0 {
+				t.Fatalf("exit status %d\n%s", code, stderr)
+			}
+			for _, name := range []string{"--format", "--verify-all", "validate"} {
+				if !strings.Contains(stdout, strings.TrimPrefix(name, "-")) {
+					t.Errorf("completion does not mention %s", name)
+				}
+			}
+			checkShellSyntax(t, shell, stdout)
+		})
+	}
+}
+
+func TestCompletionQuotesUsage(t *testing.T) {
+	fs := flag.NewFlagSet(programName, flag.ContinueOnError)
+	fs.String("format", "json", `Output "format" [it's: json]`)
+	fs.Bool("quiet", false, `Don't print \ anything`)
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCompletion(&buf, shell, fs); err != nil {
+				t.Fatal(err)
+			}
+			checkShellSyntax(t, shell, buf.String())
+		})
+	}
+
+	if err := writeCompletion(&bytes.Buffer{}, "tcsh", fs); err == nil {
+		t.Error("no error for an unsupported shell")
+	}
+}
+
+func TestBashCompletionCompletesValues(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	var buf bytes.Buffer
+	if err := writeCompletion(&buf, "bash", flag.CommandLine); err != nil {
+		t.Fatal(err)
+	}
+	complete := func(words ...string) string {
+		script := buf.String() + "COMP_WORDS=(" + strings.Join(words, " ") + ")\n" +
+			"COMP_CWORD=$((${#COMP_WORDS[@]} - 1))\n_nim_targetlist\necho \"${COMPREPLY[*]}\"\n"
+		out, err := exec.Command(bash, "-c", script).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if got := complete(programName, "completion", "f"); got != "fish" {
+		t.Errorf("completion f completes to %q, want fish", got)
+	}
+	if got := complete(programName, "val"); got != "validate" {
+		t.Errorf("val completes to %q, want validate", got)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/exec"
+)
+
+// probeCPUsPerOS asks nim for the CPUs it accepts alongside each OS by
+// pairing --os:X with an invalid --cpu. Some nim versions only reject a CPU
+// in the context of a particular OS, so these lists are narrower than the
+// global one. OSes whose probe yields nothing are left out of the result
+// and keep the global CPU list.
+func (ts *TargetScanner) probeCPUsPerOS(ctx context.Context, oses []string) map[string]map[string]bool {
+	if !ts.nimAvailable {
+		return nil
+	}
+
+	perOS := make(map[string]map[string]bool)
+	for _, osName := range oses {
+		if ctx.Err() != nil {
+			return nil
+		}
+		args := []string{"--os:" + osName, "--cpu:invalid", "c"}
+
+		probeCtx, cancel := context.WithTimeout(ctx, ts.timeout)
+		output, err := exec.CommandContext(probeCtx, ts.nimBinary, args...).CombinedOutput()
+		cancel()
+
+		ts.noteExecError(ctx, args, err)
+		ts.dumpRaw("cpu-"+osName, args, output)
+
+		cpus, _ := ts.parseHelpOutput(string(output), "cpu")
+		if len(cpus) == 0 {
+			if ts.debugMode {
+				log.Printf("No OS-specific CPU list for %s; using the global list", osName)
+			}
+			continue
+		}
+
+		allowed := make(map[string]bool, len(cpus))
+		for _, cpu := range cpus {
+			allowed[cpu] = true
+		}
+		perOS[osName] = allowed
+	}
+
+	log.Printf("Probed OS-specific CPU lists for %d of %d OSes", len(perOS), len(oses))
+	return perOS
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// perOSNim lists every CPU for a plain --cpu:invalid, but only three when
+// it is paired with windows.
+const perOSNim = `case "$*" in
+--version) echo "Nim Compiler Version 2.0.2" ;;
+"--os:windows --cpu:invalid c") echo "available options are: amd64, i386, arm64"; exit 1 ;;
+--os:invalid*) echo "available options are: linux, windows, freebsd"; exit 1 ;;
+--cpu:invalid*) echo "available options are: amd64, i386, arm, arm64"; exit 1 ;;
+*) exit 1 ;;
+esac
+`
+
+func TestProbeCPUsPerOS(t *testing.T) {
+	ts := stubNimScanner(t, perOSNim)
+	got := ts.probeCPUsPerOS(context.Background(), []string{"windows", "linux"})
+	want := map[string]map[string]bool{
+		"windows": {"amd64": true, "i386": true, "arm64": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("probeCPUsPerOS() = %v, want %v", got, want)
+	}
+}
+
+func TestProbePerOSNarrowsTargets(t *testing.T) {
+	ts := stubNimScanner(t, perOSNim)
+	ts.skipVerify = true
+	ts.probePerOS = true
+	ts.osFilter = []string{"linux", "windows"}
+	ts.cpuFilter = []string{"amd64", "arm"}
+	var got []string
+	for _, target := range ts.scanTargets(context.Background()) {
+		got = append(got, target.OS+"/"+target.CPU)
+	}
+	sort.Strings(got)
+	// windows/arm is left out, while linux keeps the global list
+	want := []string{"linux/amd64", "linux/arm", "windows/amd64"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets %v, want %v", got, want)
+	}
+}
+
+func TestTrustDetection(t *testing.T) {
+	scan := func(nim string, trust bool) map[string]string {
+		ts := stubNimScanner(t, nim)
+		ts.skipVerify = true
+		ts.trustDetection = trust
+		ts.osFilter = []string{"linux", "haiku"}
+		ts.cpuFilter = []string{"amd64", "mips"}
+		reasons := make(map[string]string)
+		for _, target := range ts.scanTargets(context.Background()) {
+			reasons[target.OS+"/"+target.CPU] = target.SkipReason
+		}
+		return reasons
+	}
+
+	// haiku and mips are only built in, not printed by nim
+	want := map[string]string{
+		"linux/amd64": "",
+		"linux/mips":  "detected_invalid",
+		"haiku/amd64": "detected_invalid",
+		"haiku/mips":  "detected_invalid",
+	}
+	if got := scan(perOSNim, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("with --trust-detection: %v, want %v", got, want)
+	}
+	for name, reason := range scan(perOSNim, false) {
+		if reason != "" {
+			t.Errorf("%s marked %s without --trust-detection", name, reason)
+		}
+	}
+
+	// An axis nim printed nothing for is not held against any target. The
+	// CPU query falls back on --version, so it has to print too few words
+	// to pass for a list
+	noCPUs := `case "$*" in
+--version) echo "Nim Version 2.0.2" ;;
+--os:invalid*) echo "available options are: linux, windows, freebsd"; exit 1 ;;
+*) exit 1 ;;
+esac
+`
+	want = map[string]string{
+		"linux/amd64": "",
+		"linux/mips":  "",
+		"haiku/amd64": "detected_invalid",
+		"haiku/mips":  "detected_invalid",
+	}
+	if got := scan(noCPUs, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("without a detected CPU list: %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// targetDiff lists the targets that appeared or disappeared relative to a
+// baseline result, as sorted "os/cpu" names.
+type targetDiff struct {
+	Added   []string
+	Removed []string
+}
+
+// loadBaseline reads the targets of a result saved with --format json.
+func loadBaseline(path string) ([]TargetInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline TargetsResult
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline %s: %v", path, err)
+	}
+	return baseline.Targets, nil
+}
+
+// availableTargets returns the set of targets that are listed and did not
+// fail verification. Targets that were never compiled count as available,
+// so a partial verification run does not show up as removals.
+func availableTargets(targets []TargetInfo) map[string]bool {
+	available := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if target.VerifyStatus != verifyStatusFailed {
+			available[target.OS+"/"+target.CPU] = true
+		}
+	}
+	return available
+}
+
+func diffTargets(baseline, current []TargetInfo) targetDiff {
+	before := availableTargets(baseline)
+	after := availableTargets(current)
+
+	var diff targetDiff
+	for name := range after {
+		if !before[name] {
+			diff.Added = append(diff.Added, name)
+		}
+	}
+	for name := range before {
+		if !after[name] {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	return diff
+}
+
+// outputDiffMarkdown writes diff as a markdown snippet suitable for a pull
+// request comment. Both sections are always present so bots can anchor on
+// them.
+func outputDiffMarkdown(w io.Writer, diff targetDiff, baselinePath string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Nim targets compared to `%s`\n", filepath.Base(baselinePath))
+
+	section := func(title string, names []string) {
+		fmt.Fprintf(&b, "\n#### %s (%d)\n\n", title, len(names))
+		if len(names) == 0 {
+			b.WriteString("_None_\n")
+			return
+		}
+		for _, name := range names {
+			fmt.Fprintf(&b, "- `%s`\n", name)
+		}
+	}
+	section("Added", diff.Added)
+	section("Removed", diff.Removed)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
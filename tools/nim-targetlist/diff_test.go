@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffTargets(t *testing.T) {
+	baseline := []TargetInfo{
+		{OS: "linux", CPU: "amd64", VerifyStatus: verifyStatusVerified},
+		{OS: "linux", CPU: "arm", VerifyStatus: verifyStatusVerified},
+		{OS: "windows", CPU: "i386", VerifyStatus: verifyStatusFailed},
+		{OS: "haiku", CPU: "amd64", VerifyStatus: verifyStatusSkipped},
+	}
+	current := []TargetInfo{
+		{OS: "linux", CPU: "amd64", VerifyStatus: verifyStatusVerified},
+		{OS: "linux", CPU: "arm", VerifyStatus: verifyStatusFailed},
+		{OS: "windows", CPU: "i386", VerifyStatus: verifyStatusVerified},
+		{OS: "haiku", CPU: "amd64"},
+		{OS: "freebsd", CPU: "arm64", VerifyStatus: verifyStatusSkipped},
+	}
+	want := targetDiff{
+		Added:   []string{"freebsd/arm64", "windows/i386"},
+		Removed: []string{"linux/arm"},
+	}
+	if got := diffTargets(baseline, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffTargets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestOutputDiffMarkdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	data, err := json.Marshal(TargetsResult{Targets: []TargetInfo{{OS: "linux", CPU: "arm", VerifyStatus: verifyStatusVerified}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := loadBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	diff := diffTargets(baseline, []TargetInfo{{OS: "linux", CPU: "amd64"}, {OS: "linux", CPU: "arm64"}})
+	if err := outputDiffMarkdown(&buf, diff, path); err != nil {
+		t.Fatal(err)
+	}
+	want := "### Nim targets compared to `baseline.json`\n" +
+		"\n#### Added (2)\n\n- `linux/amd64`\n- `linux/arm64`\n" +
+		"\n#### Removed (1)\n\n- `linux/arm`\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := outputDiffMarkdown(&buf, targetDiff{}, path); err != nil {
+		t.Fatal(err)
+	}
+	want = "### Nim targets compared to `baseline.json`\n" +
+		"\n#### Added (0)\n\n_None_\n" +
+		"\n#### Removed (0)\n\n_None_\n"
+	if buf.String() != want {
+		t.Errorf("empty diff: got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBaseline(path); err == nil {
+		t.Error("invalid baseline accepted")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// osFamilies groups related OS names for --os-family.
+var osFamilies = map[string][]string{
+	"linux":    {"linux", "android"},
+	"bsd":      {"freebsd", "openbsd", "netbsd", "dragonfly"},
+	"apple":    {"macosx", "macos", "ios"},
+	"windows":  {"windows"},
+	"embedded": {"standalone", "any", "freertos", "zephyr", "nuttx"},
+}
+
+func osFamilyNames() []string {
+	names := make([]string, 0, len(osFamilies))
+	for name := range osFamilies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandOSFamilies returns the OS names of the comma-separated families.
+func expandOSFamilies(value string) ([]string, error) {
+	var oses []string
+	for _, family := range parseNameList(value) {
+		members, ok := osFamilies[family]
+		if !ok {
+			return nil, fmt.Errorf("unknown OS family %q (want one of: %s)", family, strings.Join(osFamilyNames(), ", "))
+		}
+		oses = append(oses, members...)
+	}
+	return oses, nil
+}
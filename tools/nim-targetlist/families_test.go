@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandOSFamilies(t *testing.T) {
+	got, err := expandOSFamilies("linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"linux", "android"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expandOSFamilies(linux) = %v, want %v", got, want)
+	}
+
+	got, err = expandOSFamilies(" BSD , windows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"freebsd", "openbsd", "netbsd", "dragonfly", "windows"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expandOSFamilies(bsd,windows) = %v, want %v", got, want)
+	}
+
+	if _, err := expandOSFamilies("linux,plan9"); err == nil {
+		t.Error("expandOSFamilies accepted an unknown family")
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gitlabMaxMatrixJobs is GitLab's limit on jobs generated by one
+// parallel:matrix definition.
+const gitlabMaxMatrixJobs = 200
+
+// githubMaxMatrixJobs is GitHub Actions' limit on jobs generated by one
+// strategy.matrix.
+const githubMaxMatrixJobs = 256
+
+var ciVariablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// githubMatrixEntry is one job of a GitHub Actions matrix include list.
+type githubMatrixEntry struct {
+	OS  string `json:"os"`
+	CPU string `json:"cpu"`
+}
+
+// outputGitHubMatrix writes a GitHub Actions strategy.matrix object with an
+// include entry per target, on a single line so it can be passed through
+// $GITHUB_OUTPUT and fromJSON.
+func outputGitHubMatrix(w io.Writer, targets []TargetInfo) error {
+	matrix := struct {
+		Include []githubMatrixEntry `json:"include"`
+	}{Include: []githubMatrixEntry{}}
+	for _, target := range targets {
+		matrix.Include = append(matrix.Include, githubMatrixEntry{OS: target.OS, CPU: target.CPU})
+	}
+	if len(matrix.Include) > githubMaxMatrixJobs {
+		log.Printf("Warning: %d targets exceed GitHub's limit of %d jobs per matrix",
+			len(matrix.Include), githubMaxMatrixJobs)
+	}
+
+	return json.NewEncoder(w).Encode(matrix)
+}
+
+// outputGitLabMatrix writes a GitLab CI parallel:matrix definition with one
+// entry per OS listing all of its CPUs.
+func outputGitLabMatrix(w io.Writer, targets []TargetInfo, osVar, cpuVar string) error {
+	if len(targets) > gitlabMaxMatrixJobs {
+		log.Printf("Warning: %d targets exceed GitLab's limit of %d jobs per parallel:matrix",
+			len(targets), gitlabMaxMatrixJobs)
+	}
+
+	var b strings.Builder
+	b.WriteString("parallel:\n")
+	b.WriteString("  matrix:\n")
+	for _, group := range groupTargets(targets, "os") {
+		cpus := make([]string, len(group.Members))
+		for i, cpu := range group.Members {
+			cpus[i] = strconv.Quote(cpu)
+		}
+		fmt.Fprintf(&b, "    - %s: %s\n", osVar, strconv.Quote(group.Key))
+		fmt.Fprintf(&b, "      %s: [%s]\n", cpuVar, strings.Join(cpus, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// outputCSVLong writes the same attributes as the csv format unpivoted into
+// os,cpu,attribute,value rows, the long format many analytics tools prefer.
+func outputCSVLong(w io.Writer, targets []TargetInfo) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"os", "cpu", "attribute", "value"}); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		attributes := [][2]string{
+			{"verified", strconv.FormatBool(target.Verified)},
+			{"source", target.Source},
+			{"command", target.Command},
+			{"bits", layoutBits(target.Bits)},
+			{"endian", target.Endian},
+		}
+		for _, attr := range attributes {
+			if err := writer.Write([]string{target.OS, target.CPU, attr[0], attr[1]}); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+var openMetricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// outputOpenMetrics writes summary and per-target gauges in the OpenMetrics
+// text format, terminated by the mandatory "# EOF" marker.
+func outputOpenMetrics(w io.Writer, targets []TargetInfo, scanner *TargetScanner) error {
+	verified := 0
+	bySource := make(map[string]int)
+	for _, target := range targets {
+		if target.Verified {
+			verified++
+		}
+		bySource[target.Source]++
+	}
+
+	boolGauge := func(v bool) int {
+		if v {
+			return 1
+		}
+		return 0
+	}
+
+	var b strings.Builder
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, help)
+	}
+
+	metric("nim_targets", "Number of os/cpu targets in the matrix.")
+	fmt.Fprintf(&b, "nim_targets %d\n", len(targets))
+
+	metric("nim_targets_verified", "Number of targets that compiled during verification.")
+	fmt.Fprintf(&b, "nim_targets_verified %d\n", verified)
+
+	metric("nim_targets_by_source", "Number of targets by where their os/cpu names came from.")
+	sources := append([]string(nil), knownSources...)
+	for source := range bySource {
+		known := false
+		for _, s := range knownSources {
+			known = known || s == source
+		}
+		if !known {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources[len(knownSources):])
+	for _, source := range sources {
+		fmt.Fprintf(&b, "nim_targets_by_source{source=\"%s\"} %d\n",
+			openMetricsLabelEscaper.Replace(source), bySource[source])
+	}
+
+	metric("nim_available", "Whether the nim compiler was found.")
+	fmt.Fprintf(&b, "nim_available %d\n", boolGauge(scanner.nimAvailable))
+
+	metric("nim_target_verified", "Whether a target compiled during verification.")
+	for _, target := range targets {
+		fmt.Fprintf(&b, "nim_target_verified{os=\"%s\",cpu=\"%s\"} %d\n",
+			openMetricsLabelEscaper.Replace(target.OS),
+			openMetricsLabelEscaper.Replace(target.CPU),
+			boolGauge(target.Verified))
+	}
+
+	b.WriteString("# EOF\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// jsonArrayStream writes a well-formed JSON array one element at a time, so
+// the full output never has to be held in memory.
+type jsonArrayStream struct {
+	w     io.Writer
+	count int
+}
+
+func newJSONArrayStream(w io.Writer) (*jsonArrayStream, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	return &jsonArrayStream{w: w}, nil
+}
+
+func (s *jsonArrayStream) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// The separator goes before every element but the first, so there is
+	// never a trailing comma however many elements are written.
+	sep := ",\n"
+	if s.count == 0 {
+		sep = "\n"
+	}
+	if _, err := io.WriteString(s.w, sep); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(data); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+func (s *jsonArrayStream) Close() error {
+	end := "\n]\n"
+	if s.count == 0 {
+		end = "]\n"
+	}
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// jsonTargetStream writes targets into a JSON array as verification
+// finalizes them, so each one is encoded as soon as its result is known
+// rather than once the whole scan is done. prepare readies a copy of each
+// target for output and reports whether it belongs in the output at all.
+type jsonTargetStream struct {
+	array   *jsonArrayStream
+	prepare func(*TargetInfo) bool
+	written map[[3]string]bool
+	err     error
+}
+
+func newJSONTargetStream(w io.Writer, prepare func(*TargetInfo) bool) (*jsonTargetStream, error) {
+	array, err := newJSONArrayStream(w)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonTargetStream{array: array, prepare: prepare, written: make(map[[3]string]bool)}, nil
+}
+
+// OnResult is used as the scanner's OnResult callback. The scanner
+// serializes callbacks, so no locking is needed here. The first write
+// error is kept for Finish to return.
+func (s *jsonTargetStream) OnResult(target TargetInfo) {
+	if s.err != nil {
+		return
+	}
+	s.written[streamKey(target)] = true
+	if s.prepare(&target) {
+		s.err = s.array.Write(target)
+	}
+}
+
+// Finish writes the targets verification never reported, such as those
+// that were skipped, in their original order and closes the array.
+func (s *jsonTargetStream) Finish(targets []TargetInfo) error {
+	for _, target := range targets {
+		if s.err != nil {
+			return s.err
+		}
+		if !s.written[streamKey(target)] {
+			s.OnResult(target)
+		}
+	}
+	if s.err != nil {
+		return s.err
+	}
+	return s.array.Close()
+}
+
+// streamKey identifies a target by its backend as well, since the same
+// OS and CPU can be listed once per backend.
+func streamKey(target TargetInfo) [3]string {
+	return [3]string{target.OS, target.CPU, target.Backend}
+}
+
+// hclQuote renders s as an HCL quoted string, escaping template sequences
+// as well as the usual JSON-style escapes.
+func hclQuote(s string) string {
+	quoted := strconv.Quote(s)
+	quoted = strings.ReplaceAll(quoted, "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+// outputHCL writes one "targets" block per target in HCL syntax.
+func outputHCL(w io.Writer, targets []TargetInfo) error {
+	var b strings.Builder
+	for i, target := range targets {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("targets {\n")
+		fmt.Fprintf(&b, "  os       = %s\n", hclQuote(target.OS))
+		fmt.Fprintf(&b, "  cpu      = %s\n", hclQuote(target.CPU))
+		fmt.Fprintf(&b, "  verified = %t\n", target.Verified)
+		b.WriteString("}\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var iniKeyUnsafe = regexp.MustCompile(`[\s=:;#\[\]"\\]`)
+
+// iniKey quotes an INI key if it contains characters that would otherwise
+// end or confuse the key.
+func iniKey(key string) string {
+	if !iniKeyUnsafe.MatchString(key) {
+		return key
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key) + `"`
+}
+
+// outputINI writes a [summary] section with the counts and a [targets]
+// section with one "os/cpu = verified" entry per target.
+func outputINI(w io.Writer, targets []TargetInfo, scanner *TargetScanner) error {
+	verifiedCount, detectedCount, hardcodedCount := countTargets(targets)
+
+	var b strings.Builder
+	b.WriteString("[summary]\n")
+	fmt.Fprintf(&b, "total_count = %d\n", len(targets))
+	fmt.Fprintf(&b, "verified_count = %d\n", verifiedCount)
+	fmt.Fprintf(&b, "detected_count = %d\n", detectedCount)
+	fmt.Fprintf(&b, "hardcoded_count = %d\n", hardcodedCount)
+	fmt.Fprintf(&b, "nim_available = %t\n", scanner.nimAvailable)
+	fmt.Fprintf(&b, "nim_version = %s\n", scanner.nimVersion)
+	b.WriteString("\n[targets]\n")
+	for _, target := range targets {
+		fmt.Fprintf(&b, "%s = %t\n", iniKey(target.OS+"/"+target.CPU), target.Verified)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// logfmtValue quotes a logfmt value when it is empty or contains spaces,
+// quotes, equals signs or control characters.
+func logfmtValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " =\"\\") || strings.IndexFunc(v, func(r rune) bool { return r < ' ' }) >= 0 {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// outputLogfmt writes one logfmt line per target followed by a summary line.
+func outputLogfmt(w io.Writer, targets []TargetInfo, scanner *TargetScanner) error {
+	verifiedCount, detectedCount, hardcodedCount := countTargets(targets)
+
+	var b strings.Builder
+	for _, target := range targets {
+		fmt.Fprintf(&b, "os=%s cpu=%s verified=%t source=%s\n",
+			logfmtValue(target.OS), logfmtValue(target.CPU), target.Verified, logfmtValue(target.Source))
+	}
+	fmt.Fprintf(&b, "msg=summary total_count=%d verified_count=%d detected_count=%d hardcoded_count=%d nim_available=%t nim_version=%s\n",
+		len(targets), verifiedCount, detectedCount, hardcodedCount, scanner.nimAvailable, logfmtValue(scanner.nimVersion))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownCell escapes a value for a GitHub-flavored Markdown table cell.
+func markdownCell(v string) string {
+	v = strings.ReplaceAll(v, "|", "\\|")
+	return strings.ReplaceAll(v, "\n", " ")
+}
+
+// outputMarkdown writes the targets as a GitHub-flavored Markdown table.
+func outputMarkdown(w io.Writer, targets []TargetInfo) error {
+	var b strings.Builder
+	b.WriteString("| OS | CPU | Verified | Source |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, target := range targets {
+		fmt.Fprintf(&b, "| %s | %s | %t | %s |\n",
+			markdownCell(target.OS), markdownCell(target.CPU), target.Verified, markdownCell(target.Source))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
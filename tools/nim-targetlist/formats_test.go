@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// sampleTargets is a small result with one verified target and one whose
+// names need quoting in most formats.
+func sampleTargets() ([]TargetInfo, *TargetScanner) {
+	targets := []TargetInfo{
+		{OS: "linux", CPU: "amd64", Verified: true, Source: "detected", Command: "nim c --os:linux --cpu:amd64", Bits: 64, Endian: "little"},
+		{OS: "my os", CPU: `a"b`, Source: "hardcoded"},
+	}
+	scanner := NewTargetScanner()
+	scanner.nimAvailable = true
+	scanner.nimVersion = "2.0.2"
+	return targets, scanner
+}
+
+func TestOutputOpenMetrics(t *testing.T) {
+	targets, scanner := sampleTargets()
+	var out bytes.Buffer
+	if err := outputOpenMetrics(&out, targets, scanner); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := out.String()
+	if !strings.HasSuffix(doc, "\n# EOF\n") || strings.Count(doc, "# EOF") != 1 {
+		t.Fatalf("output does not end with a single # EOF marker:\n%s", doc)
+	}
+
+	typed, helped := make(map[string]bool), make(map[string]bool)
+	samples := 0
+	for _, line := range strings.Split(strings.TrimSuffix(doc, "# EOF\n"), "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# TYPE "):
+			fields := strings.Fields(line)
+			if len(fields) != 4 || fields[3] != "gauge" {
+				t.Errorf("malformed TYPE line %q", line)
+			}
+			typed[fields[2]] = true
+		case strings.HasPrefix(line, "# HELP "):
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				t.Errorf("HELP line without text: %q", line)
+			}
+			helped[fields[2]] = true
+		default:
+			samples++
+			name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]
+			if !typed[name] || !helped[name] {
+				t.Errorf("sample %q precedes the TYPE and HELP lines of %s", line, name)
+			}
+		}
+	}
+	if samples == 0 {
+		t.Error("no samples written")
+	}
+	for _, want := range []string{
+		`nim_target_verified{os="linux",cpu="amd64"} 1`,
+		`nim_target_verified{os="my os",cpu="a\"b"} 0`,
+		`nim_targets_verified 1`,
+	} {
+		if !strings.Contains(doc, want+"\n") {
+			t.Errorf("output lacks %q:\n%s", want, doc)
+		}
+	}
+}
+
+func TestOutputHCL(t *testing.T) {
+	targets, _ := sampleTargets()
+	targets = append(targets, TargetInfo{OS: "${path}", CPU: "%{x}"})
+	var out bytes.Buffer
+	if err := outputHCL(&out, targets); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `targets {
+  os       = "linux"
+  cpu      = "amd64"
+  verified = true
+}
+
+targets {
+  os       = "my os"
+  cpu      = "a\"b"
+  verified = false
+}
+
+targets {
+  os       = "$${path}"
+  cpu      = "%%{x}"
+  verified = false
+}
+`
+	if out.String() != want {
+		t.Errorf("outputHCL:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestOutputINI(t *testing.T) {
+	targets, scanner := sampleTargets()
+	var out bytes.Buffer
+	if err := outputINI(&out, targets, scanner); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[summary]
+total_count = 2
+verified_count = 1
+detected_count = 1
+hardcoded_count = 1
+nim_available = true
+nim_version = 2.0.2
+
+[targets]
+linux/amd64 = true
+"my os/a\"b" = false
+`
+	if out.String() != want {
+		t.Errorf("outputINI:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestOutputCSVLong(t *testing.T) {
+	targets, _ := sampleTargets()
+	var out bytes.Buffer
+	if err := outputCSVLong(&out, targets[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `os,cpu,attribute,value
+linux,amd64,verified,true
+linux,amd64,source,detected
+linux,amd64,command,nim c --os:linux --cpu:amd64
+linux,amd64,bits,64
+linux,amd64,endian,little
+`
+	if out.String() != want {
+		t.Errorf("outputCSVLong:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestOutputLogfmt(t *testing.T) {
+	targets, scanner := sampleTargets()
+	var out bytes.Buffer
+	if err := outputLogfmt(&out, targets, scanner); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `os=linux cpu=amd64 verified=true source=detected
+os="my os" cpu="a\"b" verified=false source=hardcoded
+msg=summary total_count=2 verified_count=1 detected_count=1 hardcoded_count=1 nim_available=true nim_version=2.0.2
+`
+	if out.String() != want {
+		t.Errorf("outputLogfmt:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestJSONTargetStreamEncodesFromCallback(t *testing.T) {
+	ts := stubNimScanner(t, "cat >/dev/null\ncase \"$*\" in *--cpu:arm\\ *) exit 1;; esac\n")
+	targets := poolTargets()
+
+	var buf bytes.Buffer
+	stream, err := newJSONTargetStream(&buf, func(target *TargetInfo) bool {
+		redactTarget(target)
+		if target.OS == "linux" {
+			target.OS = "Linux"
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported := 0
+	ts.OnResult = func(target TargetInfo) {
+		before := buf.Len()
+		stream.OnResult(target)
+		if buf.Len() == before {
+			t.Errorf("%s/%s was not written when verification reported it", target.OS, target.CPU)
+		}
+		reported++
+	}
+	targets = ts.verifyTargets(context.Background(), targets)
+	if reported == 0 || reported == len(targets) {
+		t.Fatalf("%d of %d targets reported; the test needs some left for Finish", reported, len(targets))
+	}
+	if err := stream.Finish(targets); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []TargetInfo
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("streamed output is not a JSON array: %v\n%s", err, buf.String())
+	}
+	if len(got) != len(targets) {
+		t.Fatalf("streamed %d targets, want %d", len(got), len(targets))
+	}
+	seen := make(map[string]bool)
+	for _, target := range got {
+		key := target.OS + "/" + target.CPU
+		if seen[key] {
+			t.Errorf("%s streamed twice", key)
+		}
+		seen[key] = true
+		if target.OS == "linux" || target.Command != "" {
+			t.Errorf("%s was not prepared for output: %+v", key, target)
+		}
+	}
+	if got := seen["Linux/arm"]; !got {
+		t.Error("Linux/arm missing from the stream")
+	}
+}
+
+func TestJSONTargetStreamEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	stream, err := newJSONTargetStream(&buf, func(*TargetInfo) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, _ := sampleTargets()
+	if err := stream.Finish(targets); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("got %q, want an empty array", buf.String())
+	}
+}
+
+func TestJSONTargetStreamKeepsBackendsApart(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "linux", CPU: "amd64", Backend: "c"},
+		{OS: "linux", CPU: "amd64", Backend: "cpp"},
+	}
+	var buf bytes.Buffer
+	stream, err := newJSONTargetStream(&buf, func(*TargetInfo) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.OnResult(targets[0])
+	if err := stream.Finish(targets); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []TargetInfo
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("streamed output is not a JSON array: %v\n%s", err, buf.String())
+	}
+	if len(got) != 2 || got[0].Backend != "c" || got[1].Backend != "cpp" {
+		t.Errorf("streamed %+v, want linux/amd64 once per backend", got)
+	}
+}
+
+func TestOutputGitLabMatrix(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "windows", CPU: "amd64"},
+		{OS: "linux", CPU: "amd64"},
+		{OS: "linux", CPU: "arm64"},
+		{OS: `my "os"`, CPU: "i386"},
+	}
+	var buf bytes.Buffer
+	if err := outputGitLabMatrix(&buf, targets, "TARGET_OS", "TARGET_CPU"); err != nil {
+		t.Fatal(err)
+	}
+	want := `parallel:
+  matrix:
+    - TARGET_OS: "linux"
+      TARGET_CPU: ["amd64", "arm64"]
+    - TARGET_OS: "my \"os\""
+      TARGET_CPU: ["i386"]
+    - TARGET_OS: "windows"
+      TARGET_CPU: ["amd64"]
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// graphQLSchema derives GraphQL SDL type definitions from the JSON shape of
+// the given root type, so a gateway schema can be generated rather than
+// kept in sync by hand. Fields are named after their JSON keys, embedded
+// structs are inlined as encoding/json does, and maps become a JSON scalar
+// since GraphQL has no map type. Fields that are always present in the
+// JSON output are non-null.
+func graphQLSchema(root reflect.Type) string {
+	var (
+		order    []reflect.Type
+		seen     = make(map[reflect.Type]bool)
+		needJSON bool
+	)
+
+	var typeName func(t reflect.Type) string
+	typeName = func(t reflect.Type) string {
+		switch t.Kind() {
+		case reflect.String:
+			return "String"
+		case reflect.Bool:
+			return "Boolean"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			return "Int"
+		case reflect.Float32, reflect.Float64:
+			return "Float"
+		case reflect.Slice, reflect.Array:
+			return "[" + typeName(t.Elem()) + "!]"
+		case reflect.Ptr:
+			return typeName(t.Elem())
+		case reflect.Struct:
+			if !seen[t] {
+				seen[t] = true
+				order = append(order, t)
+			}
+			return t.Name()
+		}
+		needJSON = true
+		return "JSON"
+	}
+
+	var writeFields func(b *strings.Builder, t reflect.Type)
+	writeFields = func(b *strings.Builder, t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			// Like encoding/json, inline embedded structs even when their
+			// type is unexported
+			if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+				writeFields(b, field.Type)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			gqlType := typeName(field.Type)
+			kind := field.Type.Kind()
+			nullable := strings.Contains(opts, "omitempty") ||
+				kind == reflect.Slice || kind == reflect.Map || kind == reflect.Ptr
+			if !nullable {
+				gqlType += "!"
+			}
+			fmt.Fprintf(b, "  %s: %s\n", name, gqlType)
+		}
+	}
+
+	typeName(root)
+
+	var b strings.Builder
+	for i := 0; i < len(order); i++ {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "type %s {\n", order[i].Name())
+		writeFields(&b, order[i])
+		b.WriteString("}\n")
+	}
+	if needJSON {
+		b.WriteString("\nscalar JSON\n")
+	}
+	return b.String()
+}
+
+// writeGraphQLSchema writes the SDL for the JSON result document.
+func writeGraphQLSchema(w io.Writer) error {
+	_, err := io.WriteString(w, graphQLSchema(reflect.TypeOf(TargetsResult{})))
+	return err
+}
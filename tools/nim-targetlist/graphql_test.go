@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type gqlCounts struct {
+	Total int `json:"total"`
+}
+
+type gqlItem struct {
+	Name  string            `json:"name"`
+	Score float64           `json:"score,omitempty"`
+	Tags  map[string]string `json:"tags"`
+}
+
+type gqlRoot struct {
+	Items  []gqlItem `json:"items"`
+	Best   *gqlItem  `json:"best"`
+	Ready  bool      `json:"ready"`
+	Hidden string    `json:"-"`
+	secret string
+	gqlCounts
+}
+
+func TestGraphQLSchema(t *testing.T) {
+	want := `type gqlRoot {
+  items: [gqlItem!]
+  best: gqlItem
+  ready: Boolean!
+  total: Int!
+}
+
+type gqlItem {
+  name: String!
+  score: Float
+  tags: JSON
+}
+
+scalar JSON
+`
+	if got := graphQLSchema(reflect.TypeOf(gqlRoot{})); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// sdlFields returns the field names of each type in an SDL document.
+func sdlFields(sdl string) map[string][]string {
+	fields := make(map[string][]string)
+	typePattern := regexp.MustCompile(`(?s)type (\w+) \{\n(.*?)\}`)
+	for _, m := range typePattern.FindAllStringSubmatch(sdl, -1) {
+		for _, line := range strings.Split(strings.TrimSpace(m[2]), "\n") {
+			name, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+			fields[m[1]] = append(fields[m[1]], name)
+		}
+		sort.Strings(fields[m[1]])
+	}
+	return fields
+}
+
+// jsonKeys returns the keys of v's JSON object, with omitempty fields set
+// so that they appear.
+func jsonKeys(t *testing.T, v interface{}) []string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestGraphQLSchemaMatchesJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeGraphQLSchema(&buf); err != nil {
+		t.Fatal(err)
+	}
+	fields := sdlFields(buf.String())
+	if got, want := fields["TargetInfo"], jsonKeys(t, populatedTarget()); !reflect.DeepEqual(got, want) {
+		t.Errorf("TargetInfo fields\n%v\nJSON keys\n%v", got, want)
+	}
+	if got, want := fields["TargetsResult"], jsonKeys(t, TargetsResult{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("TargetsResult fields\n%v\nJSON keys\n%v", got, want)
+	}
+}
+
+// populatedTarget returns a target with every field set, so that none is
+// omitted from its JSON.
+func populatedTarget() TargetInfo {
+	var target TargetInfo
+	v := reflect.ValueOf(&target).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString("x")
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			f.SetInt(1)
+		case reflect.Float64:
+			f.SetFloat(1)
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+		case reflect.Map:
+			f.Set(reflect.MakeMap(f.Type()))
+			f.SetMapIndex(reflect.New(f.Type().Key()).Elem(), reflect.New(f.Type().Elem()).Elem())
+		}
+	}
+	return target
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// groupByKeys lists the accepted values of --group-by.
+var groupByKeys = []string{"os", "cpu"}
+
+// TargetGroup nests targets under one axis: with group-by cpu, Key is a CPU
+// and Members are the OSes it is paired with.
+type TargetGroup struct {
+	Key     string   `json:"key"`
+	Members []string `json:"members"`
+	Count   int      `json:"count"`
+}
+
+type GroupedResult struct {
+	GroupBy     string        `json:"group_by"`
+	Groups      []TargetGroup `json:"groups"`
+	GroupCount  int           `json:"group_count"`
+	TargetCount int           `json:"target_count"`
+}
+
+func isValidGroupBy(by string) bool {
+	for _, key := range groupByKeys {
+		if by == key {
+			return true
+		}
+	}
+	return false
+}
+
+// groupTargets nests targets by the given axis ("os" or "cpu"). Groups are
+// sorted by key; members keep the order in which they appear in targets.
+func groupTargets(targets []TargetInfo, by string) []TargetGroup {
+	index := make(map[string]int)
+	var groups []TargetGroup
+
+	for _, target := range targets {
+		key, member := target.OS, target.CPU
+		if by == "cpu" {
+			key, member = target.CPU, target.OS
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, TargetGroup{Key: key})
+		}
+		groups[i].Members = append(groups[i].Members, member)
+		groups[i].Count++
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups
+}
+
+func outputGrouped(w io.Writer, targets []TargetInfo, by, format string) error {
+	groups := groupTargets(targets, by)
+	memberAxis := "cpus"
+	if by == "cpu" {
+		memberAxis = "oses"
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(GroupedResult{
+			GroupBy:     by,
+			Groups:      groups,
+			GroupCount:  len(groups),
+			TargetCount: len(targets),
+		})
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{by, memberAxis, "count"}); err != nil {
+			return err
+		}
+		for _, group := range groups {
+			record := []string{group.Key, strings.Join(group.Members, " "), fmt.Sprintf("%d", group.Count)}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\t%s\tCount\n", strings.ToUpper(by), strings.ToUpper(memberAxis))
+		fmt.Fprintln(tw, "───\t────\t─────")
+		for _, group := range groups {
+			fmt.Fprintf(tw, "%s\t%s\t%d\n", group.Key, strings.Join(group.Members, ", "), group.Count)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("--group-by does not support format %q", format)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func groupFixture() []TargetInfo {
+	return []TargetInfo{
+		{OS: "linux", CPU: "amd64"},
+		{OS: "linux", CPU: "arm64"},
+		{OS: "macosx", CPU: "arm64"},
+		{OS: "windows", CPU: "amd64"},
+		{OS: "android", CPU: "arm64"},
+	}
+}
+
+func TestGroupTargetsByCPU(t *testing.T) {
+	got := groupTargets(groupFixture(), "cpu")
+	want := []TargetGroup{
+		{Key: "amd64", Members: []string{"linux", "windows"}, Count: 2},
+		{Key: "arm64", Members: []string{"linux", "macosx", "android"}, Count: 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupTargets(cpu) = %+v, want %+v", got, want)
+	}
+}
+
+func TestOutputGroupedByCPU(t *testing.T) {
+	var out bytes.Buffer
+	if err := outputGrouped(&out, groupFixture(), "cpu", "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got GroupedResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.GroupBy != "cpu" || got.GroupCount != 2 || got.TargetCount != 5 {
+		t.Errorf("grouped result = %+v, want 2 cpu groups of 5 targets", got)
+	}
+	if oses := got.Groups[1].Members; !reflect.DeepEqual(oses, []string{"linux", "macosx", "android"}) {
+		t.Errorf("OSes supporting arm64 = %v", oses)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// probeIncremental reports whether this nim accepts --incremental:on. The
+// switch has been experimental for several releases and some builds reject
+// it outright, so a trivial program is checked with it before it is added
+// to every verification.
+func (ts *TargetScanner) probeIncremental(ctx context.Context) bool {
+	probeCtx, cancel := context.WithTimeout(ctx, ts.timeout)
+	defer cancel()
+
+	args := []string{"check", "--incremental:on", "--hints:off", "--warnings:off", "-"}
+	cmd := exec.CommandContext(probeCtx, ts.nimBinary, args...)
+	cmd.Stdin = strings.NewReader(verifyProgram)
+	output, err := cmd.CombinedOutput()
+	ts.noteExecError(ctx, args, err)
+
+	return verificationPassed(output, err)
+}
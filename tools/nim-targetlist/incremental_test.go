@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIncrementalProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		nim  string
+		used bool
+	}{
+		{"accepted", "cat >/dev/null\n", true},
+		{"rejected", `cat >/dev/null
+case "$*" in *--incremental:on*) echo "Error: invalid command line option: '--incremental'"; exit 1 ;; esac
+`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, calls := recordingNimScanner(t, `case "$1" in --version) echo "Nim Compiler Version 2.0.2"; exit 0;; esac
+`+tt.nim)
+			ts.incremental = true
+			ts.detectNim(context.Background())
+			if ts.incrementalUsed != tt.used {
+				t.Fatalf("incremental used %v, want %v", ts.incrementalUsed, tt.used)
+			}
+			if got := calls(); len(got) != 2 || got[1] != "check --incremental:on --hints:off --warnings:off -" {
+				t.Errorf("nim ran with %q", got)
+			}
+
+			ts.verifyTarget(context.Background(), "linux", "amd64", "c")
+			last := calls()[len(calls())-1]
+			if strings.Contains(last, "--incremental:on") != tt.used {
+				t.Errorf("verification ran %q", last)
+			}
+		})
+	}
+
+	// Without --incremental nothing is probed
+	ts, calls := recordingNimScanner(t, "echo 'Nim Compiler Version 2.0.2'\n")
+	ts.detectNim(context.Background())
+	if got := calls(); len(got) != 1 || ts.incrementalUsed {
+		t.Errorf("nim ran with %q; incremental used %v", got, ts.incrementalUsed)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// knownTargetsJSON is the fallback target list used when nim cannot be
+// asked, kept as data so changes to it are easy to review.
+//
+//go:embed targets_known.json
+var knownTargetsJSON []byte
+
+var builtinTargets = mustParseKnownTargets(knownTargetsJSON)
+
+// mustParseKnownTargets decodes the embedded target list. It is part of
+// the binary, so a malformed file is a build defect and panics at startup.
+func mustParseKnownTargets(data []byte) KnownTargets {
+	var known KnownTargets
+	if err := json.Unmarshal(data, &known); err != nil {
+		panic(fmt.Sprintf("invalid embedded targets_known.json: %v", err))
+	}
+	if len(known.OSes) == 0 || len(known.CPUs) == 0 {
+		panic("embedded targets_known.json lists no OSes or CPUs")
+	}
+	return known
+}
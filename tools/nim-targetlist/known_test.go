@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestBuiltinTargetsMatchPriorLists pins targets_known.json to the OS and
+// CPU slices that were hardcoded in NewTargetScanner before it existed.
+// Extend the lists here when the file gains a target.
+func TestBuiltinTargetsMatchPriorLists(t *testing.T) {
+	oses := []string{
+		"dos", "windows", "os2", "linux", "morphos", "skyos", "solaris",
+		"irix", "netbsd", "freebsd", "openbsd", "dragonfly", "crossos",
+		"aix", "palmos", "qnx", "amiga", "atari", "netware", "macos",
+		"macosx", "ios", "haiku", "android", "vxworks", "genode", "js",
+		"nimvm", "standalone", "nintendoswitch", "freertos", "zephyr",
+		"nuttx", "any",
+	}
+	cpus := []string{
+		"i386", "m68k", "alpha", "powerpc", "powerpc64", "powerpc64el",
+		"sparc", "vm", "hppa", "ia64", "amd64", "mips", "mipsel", "arm",
+		"arm64", "js", "nimvm", "avr", "msp430", "sparc64", "mips64",
+		"mips64el", "riscv32", "riscv64", "esp", "wasm32", "e2k",
+		"loongarch64",
+	}
+	known := mustParseKnownTargets(knownTargetsJSON)
+	if !reflect.DeepEqual(known.OSes, oses) {
+		t.Errorf("embedded OSes %q, want %q", known.OSes, oses)
+	}
+	if !reflect.DeepEqual(known.CPUs, cpus) {
+		t.Errorf("embedded CPUs %q, want %q", known.CPUs, cpus)
+	}
+
+	ts := NewTargetScanner()
+	if !reflect.DeepEqual(ts.knownOSes, oses) || !reflect.DeepEqual(ts.knownCPUs, cpus) {
+		t.Error("NewTargetScanner does not start from the embedded list")
+	}
+}
+
+func TestMustParseKnownTargetsRejectsEmpty(t *testing.T) {
+	for _, data := range []string{`{"oses": ["linux"]}`, `{"cpus": `} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", data)
+				}
+			}()
+			mustParseKnownTargets([]byte(data))
+		}()
+	}
+}
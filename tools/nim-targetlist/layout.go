@@ -0,0 +1,50 @@
+package main
+
+import "strconv"
+
+// cpuLayout is the pointer size and byte order of a CPU.
+type cpuLayout struct {
+	bits   int
+	endian string
+}
+
+// cpuLayouts mirrors the bit and endian columns of nim's CPU table in
+// compiler/platform.nim. The js, vm and nimvm pseudo-CPUs have no machine
+// layout and are left out, as is any CPU not listed, so they report 0 bits
+// and no endianness rather than a guess.
+var cpuLayouts = map[string]cpuLayout{
+	"i386":        {32, "little"},
+	"m68k":        {32, "big"},
+	"alpha":       {64, "little"},
+	"powerpc":     {32, "big"},
+	"powerpc64":   {64, "big"},
+	"powerpc64el": {64, "little"},
+	"sparc":       {32, "big"},
+	"sparc64":     {64, "big"},
+	"hppa":        {32, "big"},
+	"ia64":        {64, "little"},
+	"amd64":       {64, "little"},
+	"mips":        {32, "big"},
+	"mipsel":      {32, "little"},
+	"mips64":      {64, "big"},
+	"mips64el":    {64, "little"},
+	"arm":         {32, "little"},
+	"arm64":       {64, "little"},
+	"avr":         {16, "little"},
+	"msp430":      {16, "little"},
+	"riscv32":     {32, "little"},
+	"riscv64":     {64, "little"},
+	"esp":         {32, "little"},
+	"wasm32":      {32, "little"},
+	"e2k":         {64, "little"},
+	"loongarch64": {64, "little"},
+}
+
+// layoutBits formats a pointer size for the text formats, leaving it blank
+// when unknown.
+func layoutBits(bits int) string {
+	if bits == 0 {
+		return ""
+	}
+	return strconv.Itoa(bits)
+}
@@ -4,37 +4,103 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"text/tabwriter"
 	"time"
+	"unicode/utf8"
 )
 
 type TargetInfo struct {
-	OS       string `json:"os"`
-	CPU      string `json:"cpu"`
-	Verified bool   `json:"verified"`
-	Source   string `json:"source"`
-	Command  string `json:"command"`
+	OS         string  `json:"os"`
+	CPU        string  `json:"cpu"`
+	Verified   bool    `json:"verified"`
+	Source     string  `json:"source"`
+	Command    string  `json:"command"`
+	CrossOnly  bool    `json:"cross_only"`
+	Confidence float64 `json:"confidence"`
+	// Backend is the nim command to verify with: a backend such as c or js,
+	// or check for targets that only exist at compile time
+	Backend string `json:"backend,omitempty"`
+	// SkipReason explains why an eligible target was not verified
+	SkipReason string `json:"skip_reason,omitempty"`
+	// BinarySizeBytes is the size of the linked test program (--measure-size)
+	BinarySizeBytes int64 `json:"binary_size_bytes,omitempty"`
+	// RuntimeWarning notes that a verified target still needs a special
+	// runtime or libc before it can produce a runnable binary
+	RuntimeWarning string `json:"runtime_warning,omitempty"`
+	// AppModes records whether a verified target also builds with each
+	// requested --app mode (--app-modes)
+	AppModes map[string]bool `json:"app_modes,omitempty"`
+	// Deprecated is set when nim reported the OS or CPU as deprecated
+	// while verifying it; the target may still be verified
+	Deprecated bool `json:"deprecated,omitempty"`
+	// VerifyOutput is nim's combined output from verifying the target,
+	// kept only when the scanner's RetainVerifyOutput is set
+	VerifyOutput string `json:"verify_output,omitempty"`
+	// Usable is false for pseudo-OSes (any, standalone) that this nim
+	// cannot use for a normal compile
+	Usable bool `json:"usable"`
+	// VerifyStatus tells a failed compile apart from one never attempted:
+	// verified, failed or skipped. Verified is true only for verified.
+	VerifyStatus string `json:"verify_status"`
+	// Aliases lists the other names of the target's OS or CPU that were
+	// collapsed into it
+	Aliases []string `json:"aliases,omitempty"`
+	// Bits and Endian describe the CPU's pointer size and byte order
+	// ("little" or "big"); 0 and empty when the CPU is not in cpuLayouts
+	Bits   int    `json:"bits"`
+	Endian string `json:"endian"`
+	// Triple is the GNU-style target triple, e.g. aarch64-linux-gnu, or
+	// empty when gnuTriples has none for the target
+	Triple string `json:"triple,omitempty"`
+	// FailReason is nim's first error line, or the timeout or exec error,
+	// for a target whose verification ran and failed
+	FailReason string `json:"fail_reason,omitempty"`
 }
 
+// Values of TargetInfo.VerifyStatus.
+const (
+	verifyStatusVerified = "verified"
+	verifyStatusFailed   = "failed"
+	verifyStatusSkipped  = "skipped"
+)
+
 type TargetsResult struct {
-	Targets         []TargetInfo `json:"targets"`
-	TotalCount      int          `json:"total_count"`
-	VerifiedCount   int          `json:"verified_count"`
-	DetectedCount   int          `json:"detected_count"`
-	HardcodedCount  int          `json:"hardcoded_count"`
-	GeneratedAt     string       `json:"generated_at"`
-	VerificationRun bool         `json:"verification_run"`
-	NimAvailable    bool         `json:"nim_available"`
+	Targets []TargetInfo `json:"targets"`
+	TargetsSummary
+}
+
+// TargetsSummary holds the counts and scan details reported alongside the
+// targets; its fields are inlined in the JSON result.
+type TargetsSummary struct {
+	TotalCount      int      `json:"total_count"`
+	VerifiedCount   int      `json:"verified_count"`
+	DetectedCount   int      `json:"detected_count"`
+	HardcodedCount  int      `json:"hardcoded_count"`
+	GeneratedAt     string   `json:"generated_at"`
+	VerificationRun bool     `json:"verification_run"`
+	NimAvailable    bool     `json:"nim_available"`
+	NimVersion      string   `json:"nim_version"` // empty when nim is unavailable
+	DefaultThreads  bool     `json:"default_threads"`
+	IncrementalUsed bool     `json:"incremental_used"`
+	Backends        []string `json:"backends"` // probed only when verifying or with --detect-backends
 }
 
 type TargetScanner struct {
@@ -54,6 +120,116 @@ type TargetScanner struct {
 	selfOnly       bool
 	timeout        time.Duration
 	nimAvailable   bool
+	nimBinary      string
+	nimVersion     string
+	defaultThreads bool
+	backends       []string
+	backend        string
+	remoteListURL  string
+	sourcePriority []string
+	
+	// URL of a detached signature the remote list must verify against
+	remoteListSig string
+	
+	// Probe the usable backends even when nothing is verified
+	// (--detect-backends)
+	probeBackends bool
+	
+	order          string
+	perOSDeadline  time.Duration
+	dumpRawDir     string
+	measureSize    bool
+	minConfidence  float64
+	appModes       []string
+	matrixFile     string
+	probePerOS     bool
+	noPrune        bool
+	trustDetection bool
+	workers        int
+	osFilter       []string
+	osFamilyOSes   []string
+	verifyEnv      []string
+	
+	// Verification results persisted between runs (--cache); nil disables
+	cache *verifyCache
+	cpuFilter      []string
+	
+	// --incremental was given; incrementalUsed is set once this nim is
+	// also known to support it
+	incremental     bool
+	incrementalUsed bool
+	
+	// Directory under which each verification keeps its nimcache
+	// (--keep-artifacts); empty uses nim's default nimcache
+	artifactsDir string
+	
+	// noBackoff disables throttling --verify-all when compiles fail en
+	// masse (--no-backoff); abortAfter consecutive failures give up on the
+	// run in either verification mode (--abort-after-failures, 0 never),
+	// setting verifyAborted
+	noBackoff     bool
+	abortAfter    int
+	verifyAborted bool
+	
+	// Pairing restrictions used to prune combinations
+	compat *CompatTable
+	
+	// Targets given with --require, verified even when not common
+	required map[[2]string]bool
+	
+	// Test programs from --os-test-dir and --cpu-test-dir, keyed by name
+	osPrograms  map[string]string
+	cpuPrograms map[string]string
+	
+	// Test program from --verify-source, used instead of verifyProgram
+	// for targets without a program of their own
+	defaultProgram string
+	
+	// OS alias -> canonical name (--os-alias); noNormalize keeps aliases of
+	// both OSes and CPUs as targets of their own (--no-normalize)
+	osAliases   map[string]string
+	noNormalize bool
+	
+	// Aliases nim printed in parentheses after a target name, e.g.
+	// "amd64 (x86_64)", mapping alias -> name
+	detectedAliases map[string]string
+	
+	// Extra nim arguments for specific targets, keyed by "os/cpu"
+	tripleFlags map[string][]string
+	
+	// Extra nim arguments for every target (--nim-flag)
+	nimFlags []string
+	
+	// Set once piping the test program to nim has failed; verification
+	// then compiles it from a temporary file
+	stdinFallback atomic.Bool
+	
+	// Verifications currently running, keyed by their nim arguments
+	inflightMu sync.Mutex
+	inflight   map[string]*inflightVerify
+	
+	// Callbacks for embedders, invoked once per target processed during
+	// verification (including targets skipped by a budget or as known
+	// invalid). They may be called from worker goroutines but never
+	// concurrently with each other, and should return quickly since
+	// verification waits on them. done counts in completion order, which
+	// with --verify-all is not the order of the target slice.
+	OnProgress func(done, total int)
+	OnResult   func(TargetInfo)
+	callbackMu sync.Mutex
+	
+	// RetainVerifyOutput keeps nim's output from each verification in
+	// TargetInfo.VerifyOutput, cut to its last VerifyOutputLimit bytes
+	// (0 = unlimited).
+	RetainVerifyOutput bool
+	VerifyOutputLimit  int
+	
+	// Per-target timeout scaling; nil means a uniform timeout
+	timeoutWeights *ComplexityWeights
+	
+	// Warnings recorded during the run, reported by --strict-exit
+	warningsMu sync.Mutex
+	warnings   []string
 }
 
 func NewTargetScanner() *TargetScanner {
@@ -70,28 +246,75 @@ func NewTargetScanner() *TargetScanner {
 		cleanupPatterns: []*regexp.Regexp{
 			// Remove noise words and characters
 			regexp.MustCompile(`\b(?:or|and|the|a|an|options|are|targets|platforms|available|supported|valid|one|of)\b`),
-			regexp.MustCompile(`[:\.,;]+`),
+			// Punctuation, so the last name of a sentence such as
+			// "... arm64 and amd64." comes out clean
+			regexp.MustCompile("[:.,;!?'\"`()\\[\\]{}<>]+"),
 			regexp.MustCompile(`\s+`),
 		},
-		// Updated OS list from Nim documentation
-		knownOSes: []string{
-			"dos", "windows", "os2", "linux", "morphos", "skyos", "solaris", 
-			"irix", "netbsd", "freebsd", "openbsd", "dragonfly", "crossos", 
-			"aix", "palmos", "qnx", "amiga", "atari", "netware", "macos", 
-			"macosx", "ios", "haiku", "android", "vxworks", "genode", "js", 
-			"nimvm", "standalone", "nintendoswitch", "freertos", "zephyr", 
-			"nuttx", "any",
-		},
-		// Updated CPU list from Nim documentation
-		knownCPUs: []string{
-			"i386", "m68k", "alpha", "powerpc", "powerpc64", "powerpc64el", 
-			"sparc", "vm", "hppa", "ia64", "amd64", "mips", "mipsel", "arm", 
-			"arm64", "js", "nimvm", "avr", "msp430", "sparc64", "mips64", 
-			"mips64el", "riscv32", "riscv64", "esp", "wasm32", "e2k", 
-			"loongarch64",
-		},
-		timeout: 30 * time.Second,
+		// Fallback lists, embedded from targets_known.json
+		knownOSes:      append([]string(nil), builtinTargets.OSes...),
+		knownCPUs:      append([]string(nil), builtinTargets.CPUs...),
+		timeout:        30 * time.Second,
+		nimBinary:      "nim",
+		sourcePriority: knownSources,
+		compat:         defaultCompatTable(),
+		osAliases:      osAliases,
+		abortAfter:     defaultAbortAfter,
+	}
+}
+
+// crossOnlyCPUs can never be the host a compiler runs on, so targets using
+// them are always cross-compiled. Verification is compile-only, so no run
+// check is attempted for them (or any other target).
+var crossOnlyCPUs = map[string]bool{
+	"avr": true, "msp430": true, "esp": true, "wasm32": true,
+	"js": true, "nimvm": true,
+}
+
+// knownInvalidTargets are os/cpu pairs nim accepts on the command line but
+// that have no working toolchain or platform behind them. They are marked
+// known_invalid without spending a compile on them. Pairs the compatibility
+// table rules out, such as avr outside standalone, are pruned instead and
+// do not belong here.
+var knownInvalidTargets = map[string]bool{
+	"ios/i386":       true, // 32-bit iOS simulator is long gone
+	"macosx/i386":    true, // 32-bit macOS support was removed in 10.15
+	"macosx/powerpc": true,
+}
+
+// runtimeRequirements lists CPUs whose binaries need a dedicated runtime or
+// libc that a --compileOnly pass never exercises.
+var runtimeRequirements = map[string]string{
+	"wasm32": "requires a WebAssembly toolchain and runtime (e.g. emscripten or wasi-libc)",
+	"avr":    "requires avr-gcc and avr-libc",
+	"msp430": "requires msp430-gcc and its libc",
+	"esp":    "requires the ESP-IDF toolchain",
+}
+
+// pseudoOSes are OS names that need extra setup (a panic override, a
+// custom allocator) before nim can build anything for them.
+var pseudoOSes = []string{"any", "standalone"}
+
+// probePseudoOSes reports, for each pseudo-OS among oses, whether a plain
+// test program compiles for it on the host CPU with this nim installation.
+func (ts *TargetScanner) probePseudoOSes(ctx context.Context, oses []string) map[string]bool {
+	_, hostCPU := ts.getHostTarget()
+	usable := make(map[string]bool, len(pseudoOSes))
+	for _, osName := range pseudoOSes {
+		if !containsString(oses, osName) {
+			continue
+		}
+		result := ts.verifyTarget(ctx, osName, hostCPU, ts.backendFor(osName, hostCPU))
+		if ctx.Err() != nil {
+			// Interrupted: an unfinished probe proves nothing either way
+			return nil
+		}
+		usable[osName] = result.verified
+		if !usable[osName] {
+			log.Printf("Pseudo-OS %s is not usable for a normal compile", osName)
+		}
 	}
+	return usable
 }
 
 func (ts *TargetScanner) getHostTarget() (string, string) {
@@ -158,27 +381,27 @@ func (ts *TargetScanner) getHostTarget() (string, string) {
 	return hostOS, hostCPU
 }
 
-func debugEnvironment() {
+func debugEnvironment(nimBinary string) {
 	log.Printf("PATH from Go: %s", os.Getenv("PATH"))
 	
 	// Try to find nim using LookPath
-	nimPath, err := exec.LookPath("nim")
+	nimPath, err := exec.LookPath(nimBinary)
 	if err != nil {
-		log.Printf("exec.LookPath('nim') failed: %v", err)
+		log.Printf("exec.LookPath(%q) failed: %v", nimBinary, err)
 	} else {
-		log.Printf("exec.LookPath('nim') found: %s", nimPath)
+		log.Printf("exec.LookPath(%q) found: %s", nimBinary, nimPath)
 	}
 }
 
-func (ts *TargetScanner) checkNimAvailable() bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second) // Increased timeout
+func (ts *TargetScanner) checkNimAvailable(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, ts.timeout)
 	defer cancel()
 
     if ts.debugMode {
-	   debugEnvironment()
+	   debugEnvironment(ts.nimBinary)
 	}
 
-	cmd := exec.CommandContext(ctx, "nim", "--version")
+	cmd := exec.CommandContext(ctx, ts.nimBinary, "--version")
 	
 	// Explicitly inherit environment variables
 	cmd.Env = os.Environ()
@@ -210,6 +433,7 @@ func (ts *TargetScanner) checkNimAvailable() bool {
 		  if ts.debugMode {	
 	  	    log.Printf("nim available: %s", strings.TrimSpace(string(output)))
 		  }	
+		  ts.nimVersion = parseNimVersion(string(output))
 	  	  return true
 	  }
 	
@@ -217,11 +441,94 @@ func (ts *TargetScanner) checkNimAvailable() bool {
 	return false
 }
 
-func (ts *TargetScanner) parseHelpOutput(output string, targetType string) []string {
-	var results []string
-	seen := make(map[string]bool)
+var nimVersionPattern = regexp.MustCompile(`(?i)nim\s+compiler\s+version\s+(\d+\.\d+\.\d+)`)
+
+// parseNimVersion extracts the version number from `nim --version` output,
+// e.g. "2.0.2" from "Nim Compiler Version 2.0.2 [Linux: amd64]".
+func parseNimVersion(output string) string {
+	matches := nimVersionPattern.FindStringSubmatch(output)
+	if len(matches) < 2 {
+		return ""
+	}
+	return matches[1]
+}
+
+// defaultThreadsFor reports whether the given nim version compiles with
+// --threads:on by default, which is the case from Nim 2.0 onwards.
+func defaultThreadsFor(version string) bool {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	return err == nil && n >= 2
+}
+
+var (
+	// ANSI CSI sequences (colors, cursor movement) and OSC sequences
+	ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+	// man/less-style overstriking used for bold and underline: "_\bx", "x\bx"
+	overstrikePattern = regexp.MustCompile(`.\x08`)
+	// Prompts and status lines left behind by pagers
+	pagerLinePattern = regexp.MustCompile(`(?i)^(?:--\s*more\s*--.*|\(end\)|:|lines \d+-\d+.*)$`)
+)
+
+// cleanTerminalOutput strips terminal decoration from help output so that
+// colorized or paged text parses like plain text.
+func cleanTerminalOutput(output string) string {
+	output = ansiEscapePattern.ReplaceAllString(output, "")
+	output = overstrikePattern.ReplaceAllString(output, "")
+	output = strings.ReplaceAll(output, "\r\n", "\n")
 	
 	lines := strings.Split(output, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		// Pagers redraw lines with carriage returns; keep the final text
+		if i := strings.LastIndex(line, "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		if pagerLinePattern.MatchString(strings.TrimSpace(line)) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// Confidence assigned to detected names. Patterns anchored on phrases such
+// as "available options are:" are far more reliable than the generic
+// "looks like a list" pattern, which is always the last one.
+const (
+	phraseMatchConfidence  = 0.8
+	genericMatchConfidence = 0.4
+	knownNameBonus         = 0.2
+)
+
+// detectionConfidence scores a name extracted from help output by the
+// pattern that found it and whether it is a name we already know.
+func (ts *TargetScanner) detectionConfidence(patternIndex int, name, targetType string) float64 {
+	score := phraseMatchConfidence
+	if patternIndex == len(ts.targetListPatterns)-1 {
+		score = genericMatchConfidence
+	}
+	
+	known := ts.knownOSes
+	if targetType == "cpu" {
+		known = ts.knownCPUs
+	}
+	for _, k := range known {
+		if k == name {
+			score += knownNameBonus
+			break
+		}
+	}
+	return math.Min(score, 1.0)
+}
+
+// parseHelpOutput extracts target names from help output, along with the
+// confidence of each name (the best score among the patterns matching it).
+func (ts *TargetScanner) parseHelpOutput(output string, targetType string) ([]string, map[string]float64) {
+	var results []string
+	confidence := make(map[string]float64)
+	
+	lines := strings.Split(cleanTerminalOutput(output), "\n")
 	
 	for _, line := range lines {
 		line = strings.TrimSpace(line)
@@ -230,7 +537,7 @@ func (ts *TargetScanner) parseHelpOutput(output string, targetType string) []str
 		}
 		
 		// Try each pattern to extract target lists
-		for _, pattern := range ts.targetListPatterns {
+		for i, pattern := range ts.targetListPatterns {
 			matches := pattern.FindStringSubmatch(line)
 			if len(matches) > 1 {
 				// Use the last capture group
@@ -238,21 +545,55 @@ func (ts *TargetScanner) parseHelpOutput(output string, targetType string) []str
 				targets := ts.extractTargetsFromString(targetStr)
 				
 				for _, target := range targets {
-					if !seen[target] && ts.isValidTargetName(target, targetType) {
+					if !ts.isValidTargetName(target, targetType) {
+						continue
+					}
+					score := ts.detectionConfidence(i, target, targetType)
+					if current, seen := confidence[target]; !seen {
 						results = append(results, target)
-						seen[target] = true
+						confidence[target] = score
+					} else if score > current {
+						confidence[target] = score
 					}
 				}
 			}
 		}
 	}
 	
-	return results
+	return results, confidence
+}
+
+// parenthesizedPattern matches a description in parentheses, along with the
+// name it follows so that a one-word description can be kept as an alias.
+var (
+	parenthesizedPattern = regexp.MustCompile(`([a-z0-9_]+)?\s*\(([^()]*)\)`)
+	aliasPattern         = regexp.MustCompile(`^[a-z0-9_.-]+$`)
+)
+
+// stripParenthesized removes descriptions such as "amd64 (x86-64)" from a
+// target list, recording one-word descriptions as aliases of the name they
+// follow.
+func (ts *TargetScanner) stripParenthesized(input string) string {
+	return parenthesizedPattern.ReplaceAllStringFunc(input, func(match string) string {
+		parts := parenthesizedPattern.FindStringSubmatch(match)
+		name, alias := parts[1], strings.TrimSpace(parts[2])
+		if name != "" && alias != name && aliasPattern.MatchString(alias) {
+			if ts.detectedAliases == nil {
+				ts.detectedAliases = make(map[string]string)
+			}
+			if ts.debugMode && ts.detectedAliases[alias] != name {
+				log.Printf("Recorded alias %s for %s", alias, name)
+			}
+			ts.detectedAliases[alias] = name
+		}
+		return name
+	})
 }
 
 func (ts *TargetScanner) extractTargetsFromString(input string) []string {
 	// Clean up the input string
 	input = strings.ToLower(strings.TrimSpace(input))
+	input = ts.stripParenthesized(input)
 	
 	// Apply cleanup patterns
 	for _, pattern := range ts.cleanupPatterns {
@@ -282,7 +623,11 @@ func (ts *TargetScanner) extractTargetsFromString(input string) []string {
 	if len(targets) == 0 {
 		words := strings.Fields(input)
 		if len(words) > 2 {
-			targets = words
+			for _, word := range words {
+				if len(word) > 1 {
+					targets = append(targets, word)
+				}
+			}
 		}
 	}
 	
@@ -313,9 +658,9 @@ func (ts *TargetScanner) isValidTargetName(name, targetType string) bool {
 	return true
 }
 
-func (ts *TargetScanner) tryNimQuery(queryType string) []string {
+func (ts *TargetScanner) tryNimQuery(ctx context.Context, queryType string) ([]string, map[string]float64) {
 	if !ts.nimAvailable {
-		return nil
+		return nil, nil
 	}
 	
 	commands := [][]string{
@@ -333,229 +678,1118 @@ func (ts *TargetScanner) tryNimQuery(queryType string) []string {
 		{"dump", "--dump.format:json", "dummy"},
 	}
 	
-	for _, args := range commands {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		cmd := exec.CommandContext(ctx, "nim", args...)
+	for i, args := range commands {
+		if ctx.Err() != nil {
+			break
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, ts.timeout)
+		cmd := exec.CommandContext(queryCtx, ts.nimBinary, args...)
 		
 		output, err := cmd.CombinedOutput()
 		cancel()
 		
+		ts.noteExecError(ctx, args, err)
+		ts.dumpRaw(fmt.Sprintf("%s-%02d", queryType, i), args, output)
+		
 		if err == nil || len(output) > 0 {
-			parsed := ts.parseHelpOutput(string(output), queryType)
+			parsed, confidence := ts.parseHelpOutput(string(output), queryType)
 			if len(parsed) > 0 {
 				log.Printf("Found %d targets for %s using command: nim %s", 
 					len(parsed), queryType, strings.Join(args, " "))
-				return parsed
+				return parsed, confidence
 			}
 		}
 	}
 	
-	return nil
+	return nil, nil
 }
 
-func (ts *TargetScanner) verifyTarget(osName, cpu string) bool {
-	if !ts.nimAvailable {
-		return false
+var rawFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// dumpRaw archives the unparsed output of a detection command under the
+// --dump-raw directory, if one was given.
+func (ts *TargetScanner) dumpRaw(prefix string, args []string, output []byte) {
+	if ts.dumpRawDir == "" {
+		return
+	}
+	if err := os.MkdirAll(ts.dumpRawDir, 0o755); err != nil {
+		ts.warnf("cannot create raw output directory: %v", err)
+		return
 	}
 	
-	// Create a simple test program
-	testContent := `echo "Hello, World!"`
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-	
-	cmd := exec.CommandContext(ctx, "nim", 
-		"--os:"+osName, 
-		"--cpu:"+cpu, 
-		"--compileOnly", 
-		"--hints:off", 
+	name := prefix + "_" + strings.Trim(rawFileNameUnsafe.ReplaceAllString(strings.Join(args, "_"), "_"), "_") + ".txt"
+	if err := os.WriteFile(filepath.Join(ts.dumpRawDir, name), output, 0o644); err != nil {
+		ts.warnf("cannot write raw output: %v", err)
+	}
+}
+
+// threadlessTargets are OSes and CPUs that have no thread support, so they
+// must be verified with --threads:off when nim defaults to --threads:on.
+var threadlessTargets = map[string]bool{
+	"js": true, "nimvm": true, "standalone": true, "any": true,
+	"avr": true, "msp430": true,
+}
+
+// verifyArgs returns the nim arguments used to test-compile a target, with
+// any extra arguments placed before the stdin marker.
+func (ts *TargetScanner) verifyArgs(osName, cpu string, extra ...string) []string {
+	args := []string{
+		"--os:" + osName,
+		"--cpu:" + cpu,
+		"--compileOnly",
+		"--hints:off",
 		"--warnings:off",
-		"-")
-	
-	cmd.Stdin = strings.NewReader(testContent)
-	output, err := cmd.CombinedOutput()
-	
+	}
+	if ts.needsThreadsOff(osName, cpu) {
+		args = append(args, "--threads:off")
+	}
+	if ts.incrementalUsed {
+		args = append(args, "--incremental:on")
+	}
+	args = append(args, ts.tripleFlags[osName+"/"+cpu]...)
+	args = append(args, ts.nimFlags...)
+	args = append(args, extra...)
+	if ts.artifactsDir != "" {
+		args = append(args, "--nimcache:"+ts.artifactDir(osName, cpu, extra))
+	}
+	return append(args, "-")
+}
+
+// artifactDir is where --keep-artifacts keeps the generated sources of one
+// verification: <dir>/<os>_<cpu>, with a subdirectory named after any extra
+// arguments (e.g. c, or app_lib_c for --app:lib) so each compile has its
+// own.
+func (ts *TargetScanner) artifactDir(osName, cpu string, extra []string) string {
+	dir := filepath.Join(ts.artifactsDir, osName+"_"+cpu)
+	parts := make([]string, len(extra))
+	for i, arg := range extra {
+		parts[i] = strings.TrimLeft(arg, "-")
+	}
+	if sub := strings.Trim(rawFileNameUnsafe.ReplaceAllString(strings.Join(parts, "_"), "_"), "_"); sub != "" {
+		dir = filepath.Join(dir, sub)
+	}
+	return dir
+}
+
+// needsThreadsOff reports whether a target must be built with --threads:off
+// because nim defaults to threads on and the target has none.
+func (ts *TargetScanner) needsThreadsOff(osName, cpu string) bool {
+	return ts.defaultThreads && (threadlessTargets[osName] || threadlessTargets[cpu])
+}
+
+// nimAppModes lists the values nim accepts for --app.
+var nimAppModes = []string{"console", "gui", "lib", "staticlib"}
+
+// parseAppModes parses a comma-separated --app-modes value.
+func parseAppModes(value string) ([]string, error) {
+	var modes []string
+	for _, part := range strings.Split(value, ",") {
+		mode := strings.ToLower(strings.TrimSpace(part))
+		if mode == "" {
+			continue
+		}
+		valid := false
+		for _, m := range nimAppModes {
+			if m == mode {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown app mode %q (known: %s)", mode, strings.Join(nimAppModes, ", "))
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// verifyProgram is the default test program fed to nim on stdin during
+// verification.
+const verifyProgram = `echo "Hello, World!"`
+
+// runVerify test-compiles a single target and returns the argv used along
+// with nim's combined output.
+func (ts *TargetScanner) runVerify(ctx context.Context, osName, cpu string, extra ...string) ([]string, []byte, error) {
+	args := ts.verifyArgs(osName, cpu, extra...)
+	program := ts.testProgram(osName, cpu)
+	timeout := ts.verifyTimeout(osName, cpu)
+
+	if !ts.stdinFallback.Load() {
+		output, stdinFailed, err := ts.execVerify(ctx, args, strings.NewReader(program), timeout)
+		if !stdinFailed {
+			ts.noteExecError(ctx, args, err)
+			return append([]string{ts.nimBinary}, args...), output, err
+		}
+		// Retry from a file, and skip the pipe for all later targets
+		if ts.stdinFallback.CompareAndSwap(false, true) {
+			log.Printf("nim could not read the test program from stdin (%v); compiling from a temporary file instead", err)
+		}
+	}
+	return ts.runVerifyFile(ctx, args, program, timeout)
+}
+
+// compileEnv returns the environment for test compiles: ours plus any
+// --verify-env settings, which take precedence.
+func (ts *TargetScanner) compileEnv() []string {
+	if len(ts.verifyEnv) == 0 {
+		return nil
+	}
+	return append(os.Environ(), ts.verifyEnv...)
+}
+
+// parseVerifyEnv checks that each --verify-env value is KEY=VALUE.
+func parseVerifyEnv(values []string) ([]string, error) {
+	for _, value := range values {
+		if key, _, ok := strings.Cut(value, "="); !ok || key == "" {
+			return nil, fmt.Errorf("%q is not KEY=VALUE", value)
+		}
+	}
+	return values, nil
+}
+
+// verifyErrorIndicators are the words that mark a test compile as failed
+// even when nim exits successfully.
+var verifyErrorIndicators = []string{"error:", "invalid", "unknown", "unsupported", "failed"}
+
+// verificationPassed decides whether a test compile succeeded.
+func verificationPassed(output []byte, err error) bool {
 	if err != nil {
 		return false
 	}
-	
+
 	outputStr := strings.ToLower(string(output))
 	// Check for common error indicators
-	errorIndicators := []string{"error:", "invalid", "unknown", "unsupported", "failed"}
-	for _, indicator := range errorIndicators {
+	for _, indicator := range verifyErrorIndicators {
 		if strings.Contains(outputStr, indicator) {
 			return false
 		}
 	}
-	
+
 	return true
 }
 
-func (ts *TargetScanner) scanTargets() []TargetInfo {
-	var targets []TargetInfo
-	osSet := make(map[string]string) // os -> source
-	cpuSet := make(map[string]string) // cpu -> source
+// failReason explains a failed test compile in one line: the timeout or
+// the error starting nim, else nim's first error line, else the first
+// line that made verificationPassed reject the output.
+func failReason(output []byte, err error) string {
+	var exitErr *exec.ExitError
+	if errors.Is(err, errVerifyTimeout) || (err != nil && !errors.As(err, &exitErr)) {
+		return err.Error()
+	}
 
-	// Check if nim is available
-	ts.nimAvailable = ts.checkNimAvailable()
-	
-	// If self-only mode, just return the host target
-	if ts.selfOnly {
-		hostOS, hostCPU := ts.getHostTarget()
-		log.Printf("Host target detected: OS=%s, CPU=%s", hostOS, hostCPU)
-		
-		source := "hardcoded"
-		if !ts.hardcodedOnly && ts.nimAvailable {
-			source = "detected"
+	lines := strings.Split(string(output), "\n")
+	for _, line := range lines {
+		if strings.Contains(strings.ToLower(line), "error:") {
+			return strings.TrimSpace(line)
 		}
-		
-		return []TargetInfo{{
-			OS:      hostOS,
-			CPU:     hostCPU,
-			Source:  source,
-			Command: fmt.Sprintf("nim --os:%s --cpu:%s", hostOS, hostCPU),
-		}}
 	}
-	
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		for _, indicator := range verifyErrorIndicators {
+			if strings.Contains(lower, indicator) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+var deprecationPattern = regexp.MustCompile(`(?i)\bdeprecated\b`)
+
+// deprecationNotice reports whether nim flagged something as deprecated in
+// a test compile. Such notices are printed even with --warnings:off when
+// nim raises them at error level.
+func deprecationNotice(output []byte) bool {
+	return deprecationPattern.Match(output)
+}
+
+// truncateOutput keeps the last limit bytes of output, where nim reports
+// the error that ended a compile, and notes how much was dropped. The cut
+// is moved forward to a character boundary.
+func truncateOutput(output string, limit int) string {
+	if limit <= 0 || len(output) <= limit {
+		return output
+	}
+	cut := len(output) - limit
+	for cut < len(output) && !utf8.RuneStart(output[cut]) {
+		cut++
+	}
+	return fmt.Sprintf("[truncated %d bytes]\n", cut) + output[cut:]
+}
+
+// conclusiveVerify reports whether a compile that ended with err says
+// something lasting about the target: it ran to completion, rather than
+// timing out or failing to start nim. Only conclusive results are cached.
+func conclusiveVerify(err error) bool {
+	if err == nil {
+		return true
+	}
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && !errors.Is(err, errVerifyTimeout)
+}
+
+// verifyResult is the outcome of a single test compile.
+type verifyResult struct {
+	verified   bool
+	deprecated bool
+	failReason string // empty when verified
+	output     string // only with RetainVerifyOutput
+}
+
+// inflightVerify is a test compile in progress that other workers asking for
+// the same work can wait on instead of compiling again.
+type inflightVerify struct {
+	done   sync.WaitGroup
+	result verifyResult
+}
+
+// verifyTarget compiles the test program for osName/cpu. A compile cut
+// short by cancelling ctx reports not verified and is not cached.
+func (ts *TargetScanner) verifyTarget(ctx context.Context, osName, cpu string, extra ...string) verifyResult {
 	if !ts.nimAvailable {
-		log.Println("Warning: 'nim' command not found. Using hardcoded target list only.")
+		return verifyResult{}
 	}
-	
-	if !ts.hardcodedOnly && ts.nimAvailable {
-		log.Println("Attempting to detect targets from nim help output...")
-		
-		// Method 1: Try to parse from nim help output
-		detectedOSes := ts.tryNimQuery("os")
-		detectedCPUs := ts.tryNimQuery("cpu")
-		
-		// Add detected targets
-		for _, osName := range detectedOSes {
-			osSet[osName] = "detected"
-		}
-		for _, cpu := range detectedCPUs {
-			cpuSet[cpu] = "detected"
+
+	// Cached results carry no output, so they are not used when the
+	// output is to be retained
+	var cacheKeyStr string
+	if ts.cache != nil && ts.nimVersion != "" {
+		cacheKeyStr = cacheKey(ts.nimVersion, ts.verifyArgs(osName, cpu, extra...), ts.testProgram(osName, cpu), ts.verifyEnv)
+		if result, ok := ts.cache.get(cacheKeyStr); ok && !ts.RetainVerifyOutput {
+			return result
 		}
-		
-		log.Printf("Detected %d OSes and %d CPUs from help output", len(detectedOSes), len(detectedCPUs))
 	}
 	
-	// Method 2: Add hardcoded known targets
-	log.Println("Adding hardcoded targets...")
-	for _, osName := range ts.knownOSes {
-		if _, exists := osSet[osName]; !exists {
-			osSet[osName] = "hardcoded"
-		}
+	// Identical argv means identical work, whichever target asked for it
+	key := strings.Join(ts.verifyArgs(osName, cpu, extra...), "\x00")
+
+	ts.inflightMu.Lock()
+	if call, ok := ts.inflight[key]; ok {
+		ts.inflightMu.Unlock()
+		call.done.Wait()
+		return call.result
 	}
-	for _, cpu := range ts.knownCPUs {
-		if _, exists := cpuSet[cpu]; !exists {
-			cpuSet[cpu] = "hardcoded"
-		}
+	if ts.inflight == nil {
+		ts.inflight = make(map[string]*inflightVerify)
 	}
-	
-	log.Printf("Total unique OSes: %d, CPUs: %d", len(osSet), len(cpuSet))
-	
-	// Generate all combinations
-	var oses, cpus []string
-	for osName := range osSet {
-		oses = append(oses, osName)
+	call := &inflightVerify{}
+	call.done.Add(1)
+	ts.inflight[key] = call
+	ts.inflightMu.Unlock()
+
+	_, output, err := ts.runVerify(ctx, osName, cpu, extra...)
+	call.result = verifyResult{
+		verified:   verificationPassed(output, err),
+		deprecated: deprecationNotice(output),
 	}
-	for cpu := range cpuSet {
-		cpus = append(cpus, cpu)
+	if !call.result.verified {
+		call.result.failReason = failReason(output, err)
 	}
-	
-	sort.Strings(oses)
-	sort.Strings(cpus)
-	
-	for _, osName := range oses {
-		for _, cpu := range cpus {
-			source := "hardcoded"
-			if osSet[osName] == "detected" && cpuSet[cpu] == "detected" {
-				source = "detected"
-			} else if osSet[osName] == "detected" || cpuSet[cpu] == "detected" {
-				source = "mixed"
-			}
-			
-			targets = append(targets, TargetInfo{
-				OS:      osName,
-				CPU:     cpu,
-				Source:  source,
-				Command: fmt.Sprintf("nim --os:%s --cpu:%s", osName, cpu),
-			})
-		}
+	if ts.RetainVerifyOutput {
+		call.result.output = truncateOutput(string(output), ts.VerifyOutputLimit)
 	}
-	
-	return targets
+	if cacheKeyStr != "" && ctx.Err() == nil && conclusiveVerify(err) {
+		ts.cache.put(cacheKeyStr, call.result)
+	}
+	call.done.Done()
+
+	ts.inflightMu.Lock()
+	delete(ts.inflight, key)
+	ts.inflightMu.Unlock()
+
+	return call.result
 }
 
-func (ts *TargetScanner) verifyTargets(targets []TargetInfo) []TargetInfo {
-	// Skip verification if explicitly disabled, nim not available, or hardcoded-only mode
-	if ts.skipVerify || !ts.nimAvailable || ts.hardcodedOnly {
-		if ts.skipVerify {
-			log.Println("Skipping verification as requested.")
-		} else if ts.hardcodedOnly {
-			log.Println("Skipping verification - hardcoded-only mode.")
-		} else if !ts.nimAvailable {
-			log.Println("Skipping verification - nim command not available.")
-		}
-		return targets
+// explainVerification verifies a single target and prints everything
+// involved: the argv, the program fed on stdin, nim's output and the exit
+// status.
+func (ts *TargetScanner) explainVerification(ctx context.Context, w io.Writer, osName, cpu string) error {
+	if !ts.nimAvailable {
+		return fmt.Errorf("nim command not available")
 	}
-	
-	if !ts.verifyAll {
-		// Only verify common targets
-		commonOSes := map[string]bool{
-			"linux": true, "windows": true, "macosx": true, "freebsd": true,
-		}
-		commonCPUs := map[string]bool{
-			"amd64": true, "i386": true, "arm": true, "arm64": true,
-		}
-		
-		log.Println("Verifying common targets...")
-		for i := range targets {
-			if commonOSes[targets[i].OS] && commonCPUs[targets[i].CPU] {
-				targets[i].Verified = ts.verifyTarget(targets[i].OS, targets[i].CPU)
-			}
+
+	argv, output, err := ts.runVerify(ctx, osName, cpu, ts.backendFor(osName, cpu))
+
+	exitCode := 0
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return fmt.Errorf("running nim: %v", err)
 		}
-		return targets
+		exitCode = exitErr.ExitCode()
 	}
-	
+
+	fmt.Fprintf(w, "Target:   %s/%s\n", osName, cpu)
+	fmt.Fprintf(w, "Command:  %s\n", shellJoin(argv))
+	fmt.Fprintf(w, "Timeout:  %s\n", ts.verifyTimeout(osName, cpu))
+	fmt.Fprintf(w, "Stdin:\n%s\n", strings.TrimRight(ts.testProgram(osName, cpu), "\n"))
+	fmt.Fprintf(w, "Output:\n%s\n", strings.TrimRight(string(output), "\n"))
+	fmt.Fprintf(w, "Exit code: %d\n", exitCode)
+	fmt.Fprintf(w, "Verified: %t\n", verificationPassed(output, err))
+	fmt.Fprintf(w, "Deprecated: %t\n", deprecationNotice(output))
+	return nil
+}
+
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes s for POSIX shells unless it consists solely of
+// characters that need no quoting.
+func shellQuote(s string) string {
+	if shellSafePattern.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// targetCommand renders the nim invocation for a target so that it is safe
+// to copy-paste into a shell, whatever the os/cpu names contain. backend is
+// the nim command to use, if any.
+func (ts *TargetScanner) targetCommand(backend, osName, cpu string) string {
+	args := []string{"nim"}
+	if backend != "" {
+		args = append(args, backend)
+	}
+	args = append(args, "--os:"+osName, "--cpu:"+cpu)
+	args = append(args, ts.tripleFlags[osName+"/"+cpu]...)
+	args = append(args, ts.nimFlags...)
+	return shellJoin(args)
+}
+
+// parseTripleFlags parses --triple values of the form "os/cpu=flags" into
+// extra nim arguments per target. Flags are split on whitespace.
+func parseTripleFlags(values []string) (map[string][]string, error) {
+	triples := make(map[string][]string)
+	for _, value := range values {
+		spec, flags, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid triple %q (want os/cpu=flags)", value)
+		}
+		osName, cpu, err := parseTargetSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		key := osName + "/" + cpu
+		triples[key] = append(triples[key], strings.Fields(flags)...)
+	}
+	return triples, nil
+}
+
+// reservedNimFlags are the options verification sets itself: the target,
+// and the ones that keep nim's output parseable. They are compared the way
+// nim compares option names, ignoring case and underscores.
+var reservedNimFlags = map[string]bool{
+	"os":          true,
+	"cpu":         true,
+	"compileonly": true,
+	"c":           true,
+	"hints":       true,
+	"warnings":    true,
+	"w":           true,
+}
+
+// parseNimFlags parses --nim-flag values into extra nim arguments for every
+// verification. Flags are split on whitespace, so one value may carry
+// several, e.g. "--mm:orc -d:ssl".
+func parseNimFlags(values []string) ([]string, error) {
+	var flags []string
+	for _, value := range values {
+		for _, arg := range strings.Fields(value) {
+			if !strings.HasPrefix(arg, "-") || arg == "-" {
+				return nil, fmt.Errorf("%q is not an option", arg)
+			}
+			name := strings.TrimLeft(arg, "-")
+			if i := strings.IndexAny(name, ":="); i >= 0 {
+				name = name[:i]
+			}
+			if reservedNimFlags[strings.ToLower(strings.ReplaceAll(name, "_", ""))] {
+				return nil, fmt.Errorf("%s is set by verification itself", arg)
+			}
+			flags = append(flags, arg)
+		}
+	}
+	return flags, nil
+}
+
+// parseTargetSpec splits an "os/cpu" target specification.
+func parseTargetSpec(spec string) (string, string, error) {
+	osName, cpu, ok := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), "/")
+	if !ok || osName == "" || cpu == "" {
+		return "", "", fmt.Errorf("invalid target %q (want os/cpu)", spec)
+	}
+	return osName, cpu, nil
+}
+
+// verifyTimeout returns the compile timeout for a target: --timeout,
+// scaled by the target's complexity weight when timeout scaling is enabled.
+func (ts *TargetScanner) verifyTimeout(osName, cpu string) time.Duration {
+	base := ts.timeout
+	if ts.timeoutWeights == nil {
+		return base
+	}
+	return ts.timeoutWeights.scaleTimeout(base, osName, cpu)
+}
+
+// knownSources lists every Source a target can come from, in the default
+// merge priority order (strongest first).
+var knownSources = []string{"detected", "external", "hardcoded"}
+
+// targetSources lists every Source a combined target can have: mixed
+// targets pair a detected name with one from another source.
+var targetSources = []string{"detected", "hardcoded", "mixed", "external"}
+
+// filterBySource returns the targets whose Source is source, in their
+// original order.
+func filterBySource(targets []TargetInfo, source string) []TargetInfo {
+	var kept []TargetInfo
+	for _, target := range targets {
+		if target.Source == source {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
+
+// parseSourcePriority parses a comma-separated --source-priority value,
+// which must name every known source exactly once.
+func parseSourcePriority(value string) ([]string, error) {
+	var order []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		source := strings.ToLower(strings.TrimSpace(part))
+		known := false
+		for _, s := range knownSources {
+			if s == source {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown source %q (known: %s)", source, strings.Join(knownSources, ", "))
+		}
+		if seen[source] {
+			return nil, fmt.Errorf("source %q listed more than once", source)
+		}
+		seen[source] = true
+		order = append(order, source)
+	}
+	if len(order) != len(knownSources) {
+		return nil, fmt.Errorf("must list all sources: %s", strings.Join(knownSources, ", "))
+	}
+	return order, nil
+}
+
+func (ts *TargetScanner) sourceRank(source string) int {
+	for i, s := range ts.sourcePriority {
+		if s == source {
+			return i
+		}
+	}
+	return len(ts.sourcePriority)
+}
+
+// mergeSource records name as coming from source unless it is already known
+// from a source of higher priority.
+func (ts *TargetScanner) mergeSource(set map[string]string, name, source string) {
+	if current, exists := set[name]; !exists || ts.sourceRank(source) < ts.sourceRank(current) {
+		set[name] = source
+	}
+}
+
+// targetOrders lists the accepted values of --order.
+var targetOrders = []string{"alpha", "popularity"}
+
+// popularOSes and popularCPUs rank the most commonly targeted platforms for
+// --order popularity; anything unranked follows in alphabetical order.
+var (
+	popularOSes = []string{"linux", "windows", "macosx", "android", "ios", "freebsd", "openbsd", "netbsd"}
+	popularCPUs = []string{"amd64", "arm64", "i386", "arm", "riscv64", "wasm32", "powerpc64el"}
+)
+
+// sortByRank stably moves ranked names to the front in ranking order,
+// leaving the relative order of unranked names untouched.
+func sortByRank(names []string, ranking []string) {
+	rank := make(map[string]int, len(ranking))
+	for i, name := range ranking {
+		rank[name] = i
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		ri, iRanked := rank[names[i]]
+		rj, jRanked := rank[names[j]]
+		if iRanked && jRanked {
+			return ri < rj
+		}
+		return iRanked && !jRanked
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNameList splits a comma-separated list of target names, lowercasing
+// them for case-insensitive matching.
+func parseNameList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// filterNames keeps the names listed in filter or family, if either is
+// given, warning about filter entries that are not among the names. Family
+// members are expected to be missing from some installations and are not
+// warned about.
+func (ts *TargetScanner) filterNames(names, filter, family []string, kind, flagName string) []string {
+	if len(filter) == 0 && len(family) == 0 {
+		return names
+	}
+	
+	available := make(map[string]bool, len(names))
+	for _, name := range names {
+		available[name] = true
+	}
+	wanted := make(map[string]bool, len(filter)+len(family))
+	for _, name := range family {
+		wanted[name] = true
+	}
+	for _, name := range filter {
+		if !available[name] {
+			valid := append([]string(nil), names...)
+			sort.Strings(valid)
+			ts.warnf("unknown %s %q in %s (valid: %s)", kind, name, flagName, strings.Join(valid, ", "))
+			continue
+		}
+		wanted[name] = true
+	}
+	
+	var kept []string
+	for _, name := range names {
+		if wanted[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// detectNim probes the nim installation and records what it finds.
+func (ts *TargetScanner) detectNim(ctx context.Context) {
+	ts.nimAvailable = ts.checkNimAvailable(ctx)
+	ts.defaultThreads = defaultThreadsFor(ts.nimVersion)
+	if ts.incremental && ts.nimAvailable {
+		ts.incrementalUsed = ts.probeIncremental(ctx)
+		if !ts.incrementalUsed {
+			log.Printf("nim does not support --incremental:on; verifying without it")
+		}
+	}
+}
+
+func (ts *TargetScanner) scanTargets(ctx context.Context) []TargetInfo {
+	var targets []TargetInfo
+	osSet := make(map[string]string) // os -> source
+	cpuSet := make(map[string]string) // cpu -> source
+	var osConfidence, cpuConfidence map[string]float64 // detected name -> confidence
+	var detectedOSes, detectedCPUs []string
+
+	// Check if nim is available
+	ts.detectNim(ctx)
+	
+	// An explicit matrix replaces detection and combination entirely
+	if ts.matrixFile != "" {
+		targets, err := ts.loadMatrixFile(ts.matrixFile)
+		if err != nil {
+			log.Fatalf("Error loading matrix file: %v", err)
+		}
+		log.Printf("Loaded %d targets from %s", len(targets), ts.matrixFile)
+		return targets
+	}
+	
+	// If self-only mode, just return the host target
+	if ts.selfOnly {
+		hostOS, hostCPU := ts.getHostTarget()
+		log.Printf("Host target detected: OS=%s, CPU=%s", hostOS, hostCPU)
+		
+		source := "hardcoded"
+		if !ts.hardcodedOnly && ts.nimAvailable {
+			source = "detected"
+		}
+		
+		return []TargetInfo{{
+			OS:         hostOS,
+			CPU:        hostCPU,
+			Source:     source,
+			Backend:    ts.backendFor(hostOS, hostCPU),
+			Command:    ts.targetCommand(ts.backendFor(hostOS, hostCPU), hostOS, hostCPU),
+			Bits:       cpuLayouts[hostCPU].bits,
+			Endian:     cpuLayouts[hostCPU].endian,
+			Triple:     gnuTriples[hostOS+"/"+hostCPU],
+			Confidence: 1.0,
+			Usable:     true,
+		}}
+	}
+	
+	if !ts.nimAvailable && !ts.hardcodedOnly {
+		ts.warnf("'nim' command not found. Using hardcoded target list only.")
+	}
+	
+	if !ts.hardcodedOnly && ts.nimAvailable {
+		// Method 1: Read nim's own platform tables, or failing that parse
+		// them out of nim help output
+		if oses, cpus, err := ts.readPlatformTables(ctx); err == nil {
+			detectedOSes, osConfidence = oses, fullConfidence(oses)
+			detectedCPUs, cpuConfidence = cpus, fullConfidence(cpus)
+			log.Printf("Read %d OSes and %d CPUs from nim's platform table", len(oses), len(cpus))
+		} else {
+			log.Printf("Cannot read nim's platform table (%v)", err)
+			log.Println("Attempting to detect targets from nim help output...")
+			detectedOSes, osConfidence = ts.tryNimQuery(ctx, "os")
+			detectedCPUs, cpuConfidence = ts.tryNimQuery(ctx, "cpu")
+		}
+		
+		// Add detected targets
+		for _, osName := range detectedOSes {
+			ts.mergeSource(osSet, osName, "detected")
+		}
+		for _, cpu := range detectedCPUs {
+			ts.mergeSource(cpuSet, cpu, "detected")
+		}
+		
+		log.Printf("Detected %d OSes and %d CPUs", len(detectedOSes), len(detectedCPUs))
+		if len(detectedOSes) == 0 {
+			ts.warnf("no OSes detected from nim output; falling back to the hardcoded list")
+		}
+		if len(detectedCPUs) == 0 {
+			ts.warnf("no CPUs detected from nim output; falling back to the hardcoded list")
+		}
+		
+		// Probing builds real binaries, so it is only worth it when
+		// targets are going to be compiled anyway or it was asked for
+		if !ts.skipVerify || ts.probeBackends {
+			ts.backends = ts.detectBackends(ctx)
+			log.Printf("Usable backends: %s", strings.Join(ts.backends, ", "))
+		}
+	}
+	
+	// Method 2: Merge a remotely maintained target list, if requested
+	if ts.remoteListURL != "" {
+		remote, err := fetchRemoteList(ts.remoteListURL, ts.remoteListSig)
+		if err != nil {
+			ts.warnf("could not fetch remote target list (%v). Using built-in lists.", err)
+		} else {
+			for _, osName := range remote.OSes {
+				if !ts.isValidTargetName(osName, "os") {
+					ts.warnf("ignoring invalid OS name %q from remote list", osName)
+					continue
+				}
+				ts.mergeSource(osSet, osName, "external")
+			}
+			for _, cpu := range remote.CPUs {
+				if !ts.isValidTargetName(cpu, "cpu") {
+					ts.warnf("ignoring invalid CPU name %q from remote list", cpu)
+					continue
+				}
+				ts.mergeSource(cpuSet, cpu, "external")
+			}
+			log.Printf("Merged %d OSes and %d CPUs from remote list", len(remote.OSes), len(remote.CPUs))
+		}
+	}
+	
+	// Method 3: Add hardcoded known targets
+	log.Println("Adding hardcoded targets...")
+	for _, osName := range ts.knownOSes {
+		ts.mergeSource(osSet, osName, "hardcoded")
+	}
+	for _, cpu := range ts.knownCPUs {
+		ts.mergeSource(cpuSet, cpu, "hardcoded")
+	}
+	
+	// Historic CPU names and alternative OS names would otherwise show up
+	// as separate targets
+	if cpuConfidence == nil {
+		cpuConfidence = make(map[string]float64)
+	}
+	if osConfidence == nil {
+		osConfidence = make(map[string]float64)
+	}
+	var osAliasesOf, cpuAliasesOf map[string][]string
+	osFilter, cpuFilter := ts.osFilter, ts.cpuFilter
+	if !ts.noNormalize {
+		cpuAliasTable := ts.cpuAliasMap(cpuSet)
+		osAliasesOf = ts.collapseAliases(osSet, osConfidence, ts.osAliases)
+		cpuAliasesOf = ts.collapseAliases(cpuSet, cpuConfidence, cpuAliasTable)
+		osFilter = canonicalNames(osFilter, ts.osAliases)
+		cpuFilter = canonicalNames(cpuFilter, cpuAliasTable)
+	}
+	
+	log.Printf("Total unique OSes: %d, CPUs: %d", len(osSet), len(cpuSet))
+	
+	// Generate all combinations
+	var oses, cpus []string
+	for osName := range osSet {
+		oses = append(oses, osName)
+	}
+	for cpu := range cpuSet {
+		cpus = append(cpus, cpu)
+	}
+	
+	oses = ts.filterNames(oses, osFilter, ts.osFamilyOSes, "OS", "--os")
+	cpus = ts.filterNames(cpus, cpuFilter, nil, "CPU", "--cpu")
+	
+	sort.Strings(oses)
+	sort.Strings(cpus)
+	if ts.order == "popularity" {
+		sortByRank(oses, popularOSes)
+		sortByRank(cpus, popularCPUs)
+	}
+	
+	// Pseudo-OSes are only probed when nim can tell us and targets are
+	// being compiled anyway; otherwise they are assumed usable like every
+	// other target
+	var pseudoUsable map[string]bool
+	if ts.nimAvailable && !ts.hardcodedOnly && !ts.skipVerify {
+		pseudoUsable = ts.probePseudoOSes(ctx, oses)
+	}
+	
+	// OS-specific CPU lists narrow the cross product where nim offers them
+	var osCPUs map[string]map[string]bool
+	if ts.probePerOS && !ts.hardcodedOnly {
+		osCPUs = ts.probeCPUsPerOS(ctx, oses)
+	}
+	
+	// Names from hardcoded or external lists are trusted fully
+	axisConfidence := func(set map[string]string, confidence map[string]float64, name string) float64 {
+		if set[name] == "detected" {
+			return confidence[name]
+		}
+		return 1.0
+	}
+	
+	// With --trust-detection, a list nim printed is taken as definitive for
+	// its axis and names missing from it are not worth a compile
+	inList := func(list []string, name string) bool {
+		for _, n := range list {
+			if n == name {
+				return true
+			}
+		}
+		return len(list) == 0
+	}
+	
+	lowConfidence := 0
+	unsupported := 0
+	pruned := 0
+	for _, osName := range oses {
+		for _, cpu := range cpus {
+			if !ts.noPrune && !ts.compat.compatible(osName, cpu) {
+				if ts.debugMode {
+					log.Printf("Pruning incompatible target %s/%s", osName, cpu)
+				}
+				pruned++
+				continue
+			}
+			if allowed, ok := osCPUs[osName]; ok && !allowed[cpu] {
+				unsupported++
+				continue
+			}
+			
+			confidence := math.Min(
+				axisConfidence(osSet, osConfidence, osName),
+				axisConfidence(cpuSet, cpuConfidence, cpu))
+			if confidence < ts.minConfidence {
+				lowConfidence++
+				continue
+			}
+			
+			usable := true
+			if probed, ok := pseudoUsable[osName]; ok {
+				usable = probed
+			}
+			
+			skipReason := ""
+			if knownInvalidTargets[osName+"/"+cpu] {
+				skipReason = "known_invalid"
+			} else if !usable {
+				skipReason = "unusable"
+			} else if ts.trustDetection && !(inList(detectedOSes, osName) && inList(detectedCPUs, cpu)) {
+				skipReason = "detected_invalid"
+			}
+			
+			source := "hardcoded"
+			if osSet[osName] == "detected" && cpuSet[cpu] == "detected" {
+				source = "detected"
+			} else if osSet[osName] == "detected" || cpuSet[cpu] == "detected" {
+				source = "mixed"
+			} else if osSet[osName] == "external" || cpuSet[cpu] == "external" {
+				source = "external"
+			}
+			
+			targets = append(targets, TargetInfo{
+				OS:         osName,
+				CPU:        cpu,
+				Source:     source,
+				Backend:    ts.backendFor(osName, cpu),
+				Command:    ts.targetCommand(ts.backendFor(osName, cpu), osName, cpu),
+				CrossOnly:  crossOnlyCPUs[cpu],
+				Bits:       cpuLayouts[cpu].bits,
+				Endian:     cpuLayouts[cpu].endian,
+				Triple:     gnuTriples[osName+"/"+cpu],
+				Confidence: confidence,
+				SkipReason: skipReason,
+				Usable:     usable,
+				Aliases:    joinAliases(osAliasesOf[osName], cpuAliasesOf[cpu]),
+			})
+		}
+	}
+	
+	if pruned > 0 {
+		log.Printf("Pruned %d incompatible os/cpu combinations (see --print-compat-table; use --no-prune to keep them)", pruned)
+	}
+	if unsupported > 0 {
+		log.Printf("Excluded %d targets whose CPU nim rejects for that OS", unsupported)
+	}
+	if lowConfidence > 0 {
+		log.Printf("Excluded %d targets below confidence %.2f", lowConfidence, ts.minConfidence)
+	}
+	
+	return targets
+}
+
+// verifyWithBudget verifies target unless it was already marked as skipped
+// or its OS has exhausted the verification budget, in which case it is
+// marked budget_skipped. Once ctx is cancelled, targets are marked
+// cancelled instead.
+func (ts *TargetScanner) verifyWithBudget(ctx context.Context, target *TargetInfo, budget *osBudget) {
+	// Targets already ruled out (e.g. known_invalid) are never compiled
+	if target.SkipReason != "" {
+		return
+	}
+	if ctx.Err() != nil {
+		target.SkipReason = "cancelled"
+		return
+	}
+	if budget.exhausted(target.OS) {
+		target.SkipReason = "budget_skipped"
+		return
+	}
+	
+	// nim takes the first positional argument as its command wherever it
+	// appears, so the backend can go right before the stdin marker
+	var backend []string
+	if target.Backend != "" {
+		backend = []string{target.Backend}
+	}
+	
+	start := time.Now()
+	result := ts.verifyTarget(ctx, target.OS, target.CPU, backend...)
+	if ctx.Err() != nil {
+		// The compile was killed, so its outcome says nothing
+		target.SkipReason = "cancelled"
+		return
+	}
+	target.Verified = result.verified
+	target.VerifyStatus = verifyStatusFailed
+	if result.verified {
+		target.VerifyStatus = verifyStatusVerified
+	} else {
+		target.FailReason = result.failReason
+	}
+	target.Deprecated = result.deprecated
+	target.VerifyOutput = result.output
+	budget.charge(target.OS, time.Since(start))
+	
+	if target.Verified {
+		target.RuntimeWarning = runtimeRequirements[target.CPU]
+		
+		// Only targets that build as an executable are tried as libraries
+		for _, mode := range ts.appModes {
+			if target.AppModes == nil {
+				target.AppModes = make(map[string]bool)
+			}
+			target.AppModes[mode] = ts.verifyTarget(ctx, target.OS, target.CPU, append([]string{"--app:" + mode}, backend...)...).verified
+		}
+	}
+	
+	if target.Verified && ts.measureSize {
+		if hostOS, hostCPU := ts.getHostTarget(); target.OS == hostOS && target.CPU == hostCPU {
+			size, err := ts.measureBinarySize(ctx, target.OS, target.CPU)
+			if err != nil && ctx.Err() == nil {
+				ts.warnf("cannot measure binary size for %s/%s: %v", target.OS, target.CPU, err)
+			}
+			target.BinarySizeBytes = size
+		}
+	}
+}
+
+// markSkipped sets the status of every target verification did not attempt.
+func markSkipped(targets []TargetInfo) {
+	for i := range targets {
+		if targets[i].VerifyStatus == "" {
+			targets[i].VerifyStatus = verifyStatusSkipped
+		}
+	}
+}
+
+// notify invokes the OnResult and OnProgress callbacks for a finished
+// target. Calls are serialized, so callbacks never run concurrently even
+// though verification workers do.
+func (ts *TargetScanner) notify(target TargetInfo, done, total int) {
+	if ts.OnResult == nil && ts.OnProgress == nil {
+		return
+	}
+	
+	ts.callbackMu.Lock()
+	defer ts.callbackMu.Unlock()
+	if ts.OnResult != nil {
+		ts.OnResult(target)
+	}
+	if ts.OnProgress != nil {
+		ts.OnProgress(done, total)
+	}
+}
+
+// verifyTargets verifies targets according to the scanner's options. If
+// ctx is cancelled, running compiles are killed and the targets not yet
+// verified are marked cancelled, so the partial results can still be used.
+func (ts *TargetScanner) verifyTargets(ctx context.Context, targets []TargetInfo) []TargetInfo {
+	// Whatever path is taken, targets that were not compiled end up skipped
+	defer markSkipped(targets)
+	
+	// Skip verification if explicitly disabled, nim not available, or hardcoded-only mode
+	if ts.skipVerify || !ts.nimAvailable || ts.hardcodedOnly {
+		if ts.skipVerify {
+			log.Println("Skipping verification as requested.")
+		} else if ts.hardcodedOnly {
+			log.Println("Skipping verification - hardcoded-only mode.")
+		} else if !ts.nimAvailable {
+			log.Println("Skipping verification - nim command not available.")
+		}
+		return targets
+	}
+	
+	var budget *osBudget
+	if ts.perOSDeadline > 0 {
+		budget = newOSBudget(ts.perOSDeadline)
+	}
+	
+	// A --matrix-file lists exactly the targets to verify, so none are
+	// left out as uncommon
+	if !ts.verifyAll && ts.matrixFile == "" {
+		// Only verify common targets, plus any given with --require
+		commonOSes := map[string]bool{
+			"linux": true, "windows": true, "macosx": true, "freebsd": true,
+		}
+		commonCPUs := map[string]bool{
+			"amd64": true, "i386": true, "arm": true, "arm64": true,
+		}
+		
+		var common []int
+		for i := range targets {
+			required := ts.required[[2]string{targets[i].OS, targets[i].CPU}]
+			if (commonOSes[targets[i].OS] && commonCPUs[targets[i].CPU]) || required {
+				common = append(common, i)
+			}
+		}
+		
+		log.Println("Verifying common targets...")
+		backoff := newVerifyBackoff(1, ts.abortAfter, false)
+		for n, i := range common {
+			ts.verifyOrAbort(ctx, backoff, &targets[i], budget)
+			ts.notify(targets[i], n+1, len(common))
+		}
+		ts.noteAborted(backoff)
+		return targets
+	}
+	
 	// Verify all targets with parallel processing
 	log.Printf("Verifying all %d targets (this may take a while)...", len(targets))
 	
-	const maxWorkers = 8
-	semaphore := make(chan struct{}, maxWorkers)
+	workers := ts.workers
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+	jobs := make(chan int)
 	var wg sync.WaitGroup
-	var mu sync.Mutex
+	var progressMu sync.Mutex
+	done := 0
+	
+	backoff := newVerifyBackoff(workers, ts.abortAfter, !ts.noBackoff)
 	
-	for i := range targets {
+	// A fixed pool of workers drains the job queue; each index is handled
+	// by exactly one worker, so results can be written without locking.
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(idx int) {
+		go func() {
 			defer wg.Done()
-			semaphore <- struct{}{} // Acquire
-			defer func() { <-semaphore }() // Release
-			
-			verified := ts.verifyTarget(targets[idx].OS, targets[idx].CPU)
-			
-			mu.Lock()
-			targets[idx].Verified = verified
-			mu.Unlock()
-			
-			if idx%50 == 0 {
-				log.Printf("Verified %d/%d targets...", idx+1, len(targets))
+			for idx := range jobs {
+				ts.verifyOrAbort(ctx, backoff, &targets[idx], budget)
+				// Targets finish out of order, so progress counts
+				// completions rather than going by index; the lock keeps
+				// the counts reported in the order they were taken
+				progressMu.Lock()
+				done++
+				ts.notify(targets[idx], done, len(targets))
+				if done%50 == 0 {
+					log.Printf("Verified %d/%d targets...", done, len(targets))
+				}
+				progressMu.Unlock()
 			}
-		}(i)
+		}()
+	}
+	
+	for i := range targets {
+		jobs <- i
 	}
+	close(jobs)
 	
 	wg.Wait()
-	log.Println("Verification complete!")
+	if !ts.noteAborted(backoff) {
+		log.Println("Verification complete!")
+	}
 	
 	return targets
 }
 
-func outputJSON(targets []TargetInfo, scanner *TargetScanner) error {
-	verifiedCount := 0
-	detectedCount := 0
-	hardcodedCount := 0
-	
+// verifyOrAbort verifies target unless backoff has aborted the run, in
+// which case the target is left unverified as aborted.
+func (ts *TargetScanner) verifyOrAbort(ctx context.Context, backoff *verifyBackoff, target *TargetInfo, budget *osBudget) {
+	if !backoff.acquire() {
+		if target.SkipReason == "" {
+			target.SkipReason = "aborted"
+		}
+		return
+	}
+	ts.verifyWithBudget(ctx, target, budget)
+	backoff.release(target.OS+"/"+target.CPU, target.VerifyStatus)
+}
+
+// noteAborted records and warns about a run that backoff aborted.
+func (ts *TargetScanner) noteAborted(backoff *verifyBackoff) bool {
+	if !backoff.abortedRun() {
+		return false
+	}
+	ts.verifyAborted = true
+	ts.warnf("verification aborted after %d consecutive failures", ts.abortAfter)
+	return true
+}
+
+// countTargets returns the summary counts reported alongside the targets.
+func countTargets(targets []TargetInfo) (verifiedCount, detectedCount, hardcodedCount int) {
 	for _, target := range targets {
 		if target.Verified {
 			verifiedCount++
@@ -566,29 +1800,51 @@ func outputJSON(targets []TargetInfo, scanner *TargetScanner) error {
 			hardcodedCount++
 		}
 	}
+	return verifiedCount, detectedCount, hardcodedCount
+}
+
+// newTargetsResult wraps targets with the summary fields shared by the
+// structured output formats.
+func newTargetsResult(targets []TargetInfo, scanner *TargetScanner) TargetsResult {
+	verifiedCount, detectedCount, hardcodedCount := countTargets(targets)
+	
+	return TargetsResult{
+		Targets: targets,
+		TargetsSummary: TargetsSummary{
+			TotalCount:      len(targets),
+			VerifiedCount:   verifiedCount,
+			DetectedCount:   detectedCount,
+			HardcodedCount:  hardcodedCount,
+			GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
+			VerificationRun: scanner.verifyAll && !scanner.skipVerify,
+			NimAvailable:    scanner.nimAvailable,
+			NimVersion:      scanner.nimVersion,
+			DefaultThreads:  scanner.defaultThreads,
+			IncrementalUsed: scanner.incrementalUsed,
+			Backends:        scanner.backends,
+		},
+	}
+}
+
+func outputJSON(w io.Writer, targets []TargetInfo, scanner *TargetScanner, wrapKey string) error {
+	result := newTargetsResult(targets, scanner)
 	
-	result := TargetsResult{
-		Targets:         targets,
-		TotalCount:      len(targets),
-		VerifiedCount:   verifiedCount,
-		DetectedCount:   detectedCount,
-		HardcodedCount:  hardcodedCount,
-		GeneratedAt:     time.Now().UTC().Format(time.RFC3339),
-		VerificationRun: scanner.verifyAll && !scanner.skipVerify,
-		NimAvailable:    scanner.nimAvailable,
+	// Optionally nest the result under a top-level key for embedding
+	var doc interface{} = result
+	if wrapKey != "" {
+		doc = map[string]TargetsResult{wrapKey: result}
 	}
 	
-	encoder := json.NewEncoder(os.Stdout)
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(result)
+	return encoder.Encode(doc)
 }
 
-func outputCSV(targets []TargetInfo) error {
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
+func outputCSV(w io.Writer, targets []TargetInfo, nimVersion string) error {
+	writer := csv.NewWriter(w)
 	
 	// Write header
-	if err := writer.Write([]string{"os", "cpu", "verified", "source", "command"}); err != nil {
+	if err := writer.Write([]string{"os", "cpu", "verified", "source", "command", "nim_version", "bits", "endian", "fail_reason"}); err != nil {
 		return err
 	}
 	
@@ -600,41 +1856,165 @@ func outputCSV(targets []TargetInfo) error {
 			fmt.Sprintf("%t", target.Verified),
 			target.Source,
 			target.Command,
+			nimVersion,
+			layoutBits(target.Bits),
+			target.Endian,
+			target.FailReason,
 		}
 		if err := writer.Write(record); err != nil {
 			return err
 		}
 	}
 	
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
 
-func outputTable(targets []TargetInfo) error {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	defer w.Flush()
+func outputTable(w io.Writer, targets []TargetInfo, nimVersion string) error {
+	if nimVersion != "" {
+		fmt.Fprintf(w, "Nim %s\n\n", nimVersion)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
 	
 	// Write header
-	fmt.Fprintln(w, "OS\tCPU\tVerified\tSource\tCommand")
-	fmt.Fprintln(w, "──\t───\t────────\t──────\t───────")
+	fmt.Fprintln(tw, "OS\tCPU\tBits\tEndian\tVerified\tSource\tCommand")
+	fmt.Fprintln(tw, "──\t───\t────\t──────\t────────\t──────\t───────")
 	
 	// Write data
 	for _, target := range targets {
-		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n",
-			target.OS, target.CPU, target.Verified, target.Source, target.Command)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+			target.OS, target.CPU, layoutBits(target.Bits), target.Endian, target.Verified, target.Source, target.Command)
 	}
 	
+	return tw.Flush()
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable
+// flag.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ", ")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
+
+// exitInterrupted is the exit status after writing partial results on
+// Ctrl-C or SIGTERM, as a shell reports a process killed by SIGINT.
+const exitInterrupted = 130
+
+// outputFormats lists the accepted values of --format.
+var outputFormats = []string{"json", "csv", "csv-long", "table", "gitlab-matrix", "openmetrics", "hcl", "ini", "pretty", "protobuf", "parquet", "logfmt", "yaml", "diff-markdown", "github-matrix", "markdown", "yaml-anchors", "cache-warm"}
+
+// reportFormats lists the formats of the reports written instead of the
+// targets, keyed by the flag that asks for the report.
+var reportFormats = map[string][]string{
+	"--group-by":                    {"json", "csv", "table"},
+	"--compare-backends":            {"json", "csv", "table"},
+	"--os-scores":                   {"json", "table"},
+	"--verify-partial-order-report": {"json", "csv", "table"},
+}
+
+// checkFormat rejects a --format that is unknown, or that one of the
+// requested reports cannot be written in, before any time is spent
+// scanning.
+func checkFormat(format string, reports []string) error {
+	if !containsString(outputFormats, format) {
+		return fmt.Errorf("unknown --format %q (want one of: %s)", format, strings.Join(outputFormats, ", "))
+	}
+	for _, report := range reports {
+		if !containsString(reportFormats[report], format) {
+			return fmt.Errorf("%s supports only --format %s", report, strings.Join(reportFormats[report], ", "))
+		}
+	}
 	return nil
 }
 
 func main() {
+	var triples stringList
+	var verifyEnv stringList
+	var requires stringList
+	var osAliasFlags stringList
+	var nimFlags stringList
+	flag.Var(&nimFlags, "nim-flag", "Extra nim flags for every verification, e.g. --mm:orc or \"-d:ssl -d:release\" (repeatable)")
+	flag.Var(&osAliasFlags, "os-alias", "Treat an OS name as an alias of another, e.g. darwin=macosx (repeatable, adds to the built-in aliases)")
+	flag.Var(&requires, "require", "Target os:cpu that must verify; exit non-zero if it fails or is missing (repeatable)")
+	flag.Var(&verifyEnv, "verify-env", "Environment variable KEY=VALUE for verification compiles, e.g. CC=arm-linux-gnueabihf-gcc (repeatable)")
+	flag.Var(&triples, "triple", "Extra nim flags for one target as os/cpu=flags, e.g. standalone/arm=\"--passC:--target=arm-none-eabi\" (repeatable)")
+	
 	var (
-		format        = flag.String("format", "json", "Output format: json, csv, or table")
+		format        = flag.String("format", "json", "Output format: "+strings.Join(outputFormats, ", "))
 		verifyAll     = flag.Bool("verify-all", false, "Verify all targets (slow)")
 		skipVerify    = flag.Bool("skip-verify", false, "Skip verification entirely")
 		hardcodedOnly = flag.Bool("hardcoded-only", false, "Use only hardcoded targets (no nim dependency)")
 		selfOnly      = flag.Bool("self", false, "Show only the host target (current OS/CPU)")
 		debugMode     = flag.Bool("debug", false, "Print Debug Information (PATH etc)")
-		timeout       = flag.Duration("timeout", 30*time.Second, "Timeout for verification operations")
+		timeout       = flag.Duration("timeout", 30*time.Second, "Timeout for each nim invocation (detection and verification compiles)")
+		timeoutScale  = flag.Bool("verify-timeout-scaling", false, "Scale each target's verification timeout by its complexity weight")
+		weightsFile   = flag.String("timeout-weights", "", "JSON file of os/cpu complexity weights (implies --verify-timeout-scaling)")
+		explain       = flag.String("explain-verification", "", "Verify a single os/cpu target, print the full nim invocation and output, then exit")
+		groupBy       = flag.String("group-by", "", "Nest output by axis: "+strings.Join(groupByKeys, " or "))
+		matrixOSVar   = flag.String("matrix-os-var", "NIM_OS", "Variable name for the OS in --format gitlab-matrix")
+		matrixCPUVar  = flag.String("matrix-cpu-var", "NIM_CPU", "Variable name for the CPU in --format gitlab-matrix")
+		sourcePrio    = flag.String("source-priority", strings.Join(knownSources, ","), "Precedence of target sources when merging, strongest first")
+		perOSDeadline = flag.Duration("per-os-deadline", 0, "Compile time budget per OS; remaining targets of an OS over budget are skipped (0 = unlimited)")
+		dumpRaw       = flag.String("dump-raw", "", "Directory to save the raw output of each nim detection command")
+		measureSize   = flag.Bool("measure-size", false, "Link the test program for the host target and record the binary size")
+		streamArray   = flag.Bool("json-stream-array", false, "With --format json, write each target to a plain JSON array as soon as it is verified, without the summary")
+		scoresOnly    = flag.Bool("os-scores", false, "Report per OS the fraction of its CPUs that verified, as --format json or table, instead of the targets")
+		partialOrder  = flag.Bool("verify-partial-order-report", false, "If the run is interrupted, report which targets verified, failed or were not attempted, as --format json, csv or table, instead of the targets")
+		onlyTriple    = flag.Bool("only-with-triple", false, "Only include targets with a known GNU target triple")
+		onlySource    = flag.String("only-source", "", "Only include targets from this source: "+strings.Join(targetSources, ", "))
+		incremental   = flag.Bool("incremental", false, "Verify with --incremental:on if this nim supports it, to speed up repeated compiles")
+		compatV1      = flag.Bool("output-compat-v1", false, "With --format json, emit only the original v1 result fields, for consumers that cannot handle new ones")
+		wrapKey       = flag.String("wrap-key", "", "With --format json or yaml, nest the result under this top-level key")
+		minConfidence = flag.Float64("min-confidence", 0, "Exclude detected targets scoring below this confidence (0.0-1.0)")
+		appModes      = flag.String("app-modes", "", "Also verify each verified target with these --app modes, e.g. lib,staticlib")
+		matrixFile    = flag.String("matrix-file", "", "JSON file listing the exact os/cpu/backend targets to verify, bypassing detection")
+		backend       = flag.String("backend", "", "Verify every target with this backend ("+strings.Join(knownBackends, ", ")+"); default picks js for js targets, a check for nimvm and c otherwise")
+		compareBack   = flag.String("compare-backends", "", "Verify under two backends, e.g. c,cpp, and report targets that only work with one")
+		detectBack    = flag.Bool("detect-backends", false, "Probe which backends build a binary even with --skip-verify; verifying runs always probe them")
+		emitCfgDir    = flag.String("emit-cfg-dir", "", "Write a minimal <os>_<cpu>.nim.cfg for each verified target into this directory")
+		summaryStderr = flag.Bool("summary-json-to-stderr", false, "Also write the summary counts as one line of JSON to stderr, whatever the --format")
+		osTestDir     = flag.String("os-test-dir", "", "Directory of <os>.nim test programs to verify each OS with instead of the default")
+		cpuTestDir    = flag.String("cpu-test-dir", "", "Directory of <cpu>.nim test programs; these take precedence over --os-test-dir")
+		verifySource  = flag.String("verify-source", "", "Nim file to verify every target with instead of the built-in hello world, e.g. one importing the modules your project uses")
+		keepArtifacts = flag.String("keep-artifacts", "", "Keep each verification's generated sources in <dir>/<os>_<cpu> instead of nim's shared nimcache")
+		keepOutput    = flag.Bool("retain-verify-output", false, "Include nim's verification output for each target in the results")
+		outputLimit   = flag.Int("verify-output-limit", 4096, "Keep at most this many trailing bytes of each retained verification output (0 = unlimited)")
+		noPrune       = flag.Bool("no-prune", false, "Keep os/cpu combinations known to be incompatible, such as js/arm64")
+		noNormalize   = flag.Bool("no-normalize", false, "Keep OS and CPU aliases such as macos or x86_64 as separate targets instead of collapsing them")
+		noBackoff     = flag.Bool("no-backoff", false, "With --verify-all, keep all workers busy even if most compiles fail")
+		abortAfter    = flag.Int("abort-after-failures", defaultAbortAfter, "Give up verifying and exit non-zero after this many consecutive failed compiles (0 = never)")
+		compatFile    = flag.String("compat-table", "", "JSON file of os/cpu pairing restrictions overriding the built-in ones (see --print-compat-table)")
+		printCompat   = flag.Bool("print-compat-table", false, "Print the os/cpu pairing restrictions in effect as JSON, then exit")
+		trustDetect   = flag.Bool("trust-detection", false, "Skip compiling targets whose OS or CPU is missing from the list nim printed (marked detected_invalid)")
+		nameMapFile   = flag.String("name-map", "", "JSON file renaming OS and CPU names in the output ({\"os\": {...}, \"cpu\": {...}})")
+		concurrency   = flag.String("concurrency", "", "Set to auto to size parallel verification by CPU count and available memory")
+		workerCount   = flag.Int("workers", defaultWorkers, "Number of parallel verification compiles with --verify-all")
+		nimPath       = flag.String("nim-path", "nim", "nim compiler to run: a command looked up in PATH or a path to the binary")
+		outputFile    = flag.String("output", "", "Write the results to this file instead of stdout")
+		benchmark     = flag.Bool("benchmark-verify", false, "Compile a fixed set of targets repeatedly and report compile time statistics, then exit")
+		benchRuns     = flag.Int("benchmark-runs", 5, "Compiles per target for --benchmark-verify")
+		osFilter      = flag.String("os", "", "Only include these OSes, comma-separated (e.g. linux,freebsd)")
+		cpuFilter     = flag.String("cpu", "", "Only include these CPUs, comma-separated (e.g. amd64,arm64)")
+		osFamily      = flag.String("os-family", "", "Only include OSes of these families, comma-separated: "+strings.Join(osFamilyNames(), ", "))
+		useCache      = flag.Bool("cache", false, "Reuse verification results cached on disk for the same nim version, and cache new ones")
+		cacheFile     = flag.String("cache-file", "", "Verification cache file (default: the user cache directory's nim-targetlist/verify.json)")
+		cacheReadOnly = flag.Bool("cache-readonly", false, "With --cache, use cached verification results but never write the cache file")
+		cacheTTL      = flag.Duration("cache-ttl", 7*24*time.Hour, "Age after which cached verification results are ignored (0 = never expire)")
+		probePerOS    = flag.Bool("probe-per-os", false, "Probe nim for the CPUs valid with each OS instead of pairing every OS with every CPU")
+		order         = flag.String("order", "alpha", "Target ordering: "+strings.Join(targetOrders, " or "))
+		redact        = flag.Bool("redact", false, "Blank command lines and other environment-specific details for public sharing")
+		strictExit    = flag.Bool("strict-exit", false, "Exit non-zero if any warning occurred: fallback to hardcoded lists, parse warnings or nim exec errors")
+		verifiedOnly  = flag.Bool("verified-only", false, "Drop targets that did not verify from the output")
+		baselineFile  = flag.String("baseline", "", "Previous --format json result to compare against with --format diff-markdown")
+		remoteList    = flag.String("remote-list", "", "URL of a JSON target list ({\"oses\": [...], \"cpus\": [...]}) to merge")
+		remoteListSig = flag.String("remote-list-sig", "", "URL of an ed25519 signature of the --remote-list document; the list is rejected unless it verifies")
+		printKnown    = flag.Bool("print-known", false, "Print the built-in fallback target list as JSON, then exit")
 		help          = flag.Bool("help", false, "Show help")
 	)
 	
@@ -642,24 +2022,160 @@ func main() {
 	
 	if *help {
 		fmt.Println("Usage: nim-targetlist [options]")
+		fmt.Println("       nim-targetlist completion bash|zsh|fish")
+		fmt.Println("       nim-targetlist validate <targets.json>")
+		fmt.Println("       nim-targetlist graphql-schema")
 		fmt.Println("\nOptions:")
 		flag.PrintDefaults()
 		fmt.Println("\nThis tool scans for available Nim compilation targets by:")
 		fmt.Println("1. Parsing nim help output using regex patterns (if nim available)")
-		fmt.Println("2. Including known hardcoded targets")
-		fmt.Println("3. Optionally verifying targets by test compilation")
+		fmt.Println("2. Merging a remote target list (if --remote-list is given)")
+		fmt.Println("3. Including known hardcoded targets")
+		fmt.Println("4. Optionally verifying targets by test compilation")
 		fmt.Println("\nNotes:")
 		fmt.Println("- If nim command is not found, only hardcoded targets are used")
 		fmt.Println("- Use --hardcoded-only to skip nim detection entirely")
 		fmt.Println("- Use --skip-verify to skip all verification steps")
 		fmt.Println("- Use --self to show only the current host target")
+		fmt.Println("- If --remote-list cannot be fetched, the built-in lists are used; the same goes for a")
+		fmt.Println("  list whose --remote-list-sig signature does not match the bundled key")
+		fmt.Println("- Combinations that can never build, such as js/arm64, are pruned; --print-compat-table")
+		fmt.Println("  lists the restrictions, --compat-table overrides them and --no-prune disables pruning")
+		return
+	}
+	
+	if *printKnown {
+		if _, err := os.Stdout.Write(knownTargetsJSON); err != nil {
+			log.Fatalf("Error writing known targets: %v", err)
+		}
+		return
+	}
+	
+	// Handle subcommands that don't need a configured scanner
+	command := flag.Arg(0)
+	switch command {
+	case "", "validate":
+	case "completion":
+		if flag.NArg() != 2 {
+			log.Fatalf("Usage: %s completion %s", programName, strings.Join(completionShells, "|"))
+		}
+		if err := writeCompletion(os.Stdout, flag.Arg(1), flag.CommandLine); err != nil {
+			log.Fatalf("Error generating completion: %v", err)
+		}
+		return
+	case "graphql-schema":
+		if err := writeGraphQLSchema(os.Stdout); err != nil {
+			log.Fatalf("Error writing GraphQL schema: %v", err)
+		}
 		return
+	default:
+		log.Fatalf("Unknown command: %s", command)
 	}
 	
 	// Validate conflicting options
+	var reports []string
+	for report, used := range map[string]bool{
+		"--group-by":                    *groupBy != "",
+		"--compare-backends":            *compareBack != "",
+		"--os-scores":                   *scoresOnly,
+		"--verify-partial-order-report": *partialOrder,
+	} {
+		if used {
+			reports = append(reports, report)
+		}
+	}
+	sort.Strings(reports)
+	if err := checkFormat(*format, reports); err != nil {
+		log.Fatal(err)
+	}
 	if *verifyAll && *skipVerify {
 		log.Fatal("Cannot use --verify-all and --skip-verify together")
 	}
+	if (flagWasSet("matrix-os-var") || flagWasSet("matrix-cpu-var")) && *format != "gitlab-matrix" {
+		log.Fatal("--matrix-os-var and --matrix-cpu-var require --format gitlab-matrix")
+	}
+	for _, name := range []string{*matrixOSVar, *matrixCPUVar} {
+		if !ciVariablePattern.MatchString(name) {
+			log.Fatalf("Invalid matrix variable name %q", name)
+		}
+	}
+	if *streamArray && (*format != "json" || *groupBy != "" || *scoresOnly || *partialOrder) {
+		log.Fatal("--json-stream-array requires --format json and cannot be combined with --group-by, --os-scores or --verify-partial-order-report")
+	}
+	if *wrapKey != "" && (*format != "json" && *format != "yaml" && *format != "yaml-anchors" || *streamArray || *groupBy != "") {
+		log.Fatal("--wrap-key requires --format json, yaml or yaml-anchors and cannot be combined with --json-stream-array or --group-by")
+	}
+	if len(requires) > 0 && (*skipVerify || *hardcodedOnly) {
+		log.Fatal("--require cannot be combined with --skip-verify or --hardcoded-only")
+	}
+	if *scoresOnly && (*skipVerify || *verifiedOnly || *groupBy != "") {
+		log.Fatal("--os-scores cannot be combined with --skip-verify, --verified-only or --group-by")
+	}
+	if *abortAfter < 0 {
+		log.Fatal("--abort-after-failures cannot be negative")
+	}
+	if *partialOrder {
+		if *skipVerify || *scoresOnly || *groupBy != "" || *compareBack != "" {
+			log.Fatal("--verify-partial-order-report cannot be combined with --skip-verify, --os-scores, --group-by or --compare-backends")
+		}
+	}
+	if *format == "cache-warm" {
+		if !*useCache || *cacheReadOnly || *skipVerify || *hardcodedOnly {
+			log.Fatal("--format cache-warm requires a writable --cache and verification")
+		}
+		if *groupBy != "" || *scoresOnly || *partialOrder || *compareBack != "" {
+			log.Fatal("--format cache-warm cannot be combined with --group-by, --os-scores, --verify-partial-order-report or --compare-backends")
+		}
+	}
+	if *verifiedOnly && (*skipVerify || *hardcodedOnly) {
+		log.Fatal("--verified-only cannot be combined with --skip-verify or --hardcoded-only: no target would be left")
+	}
+	if (*baselineFile != "") != (*format == "diff-markdown") {
+		log.Fatal("--format diff-markdown and --baseline must be used together")
+	}
+	if *format == "diff-markdown" && *groupBy != "" {
+		log.Fatal("--format diff-markdown cannot be combined with --group-by")
+	}
+	if *compatV1 && (*format != "json" || *streamArray || *groupBy != "") {
+		log.Fatal("--output-compat-v1 requires --format json and cannot be combined with --json-stream-array or --group-by")
+	}
+	if *matrixFile != "" && *selfOnly {
+		log.Fatal("Cannot use --matrix-file and --self together")
+	}
+	if *backend != "" && !isKnownBackend(*backend) {
+		log.Fatalf("Invalid --backend %q (want one of: %s)", *backend, strings.Join(knownBackends, ", "))
+	}
+	var comparedBackends []string
+	if *compareBack != "" {
+		if *skipVerify || *matrixFile != "" || *groupBy != "" || *backend != "" || *verifiedOnly || *redact {
+			log.Fatal("--compare-backends cannot be combined with --skip-verify, --matrix-file, --group-by, --backend, --verified-only or --redact")
+		}
+		var err error
+		if comparedBackends, err = parseCompareBackends(*compareBack); err != nil {
+			log.Fatalf("Invalid --compare-backends: %v", err)
+		}
+	}
+	if *timeout <= 0 {
+		log.Fatalf("Invalid --timeout %s (must be positive)", *timeout)
+	}
+	if *benchRuns < 1 {
+		log.Fatalf("Invalid --benchmark-runs %d (must be at least 1)", *benchRuns)
+	}
+	if *outputLimit < 0 {
+		log.Fatalf("Invalid --verify-output-limit %d (must not be negative)", *outputLimit)
+	}
+	if *minConfidence < 0 || *minConfidence > 1 {
+		log.Fatalf("Invalid --min-confidence %v (must be between 0.0 and 1.0)", *minConfidence)
+	}
+	if *order != "alpha" && *order != "popularity" {
+		log.Fatalf("Invalid --order %q (want one of: %s)", *order, strings.Join(targetOrders, ", "))
+	}
+	if *onlySource != "" && !containsString(targetSources, *onlySource) {
+		log.Fatalf("Invalid --only-source %q (want one of: %s)", *onlySource, strings.Join(targetSources, ", "))
+	}
+	if *groupBy != "" && !isValidGroupBy(*groupBy) {
+		log.Fatalf("Invalid --group-by %q (want one of: %s)", *groupBy, strings.Join(groupByKeys, ", "))
+	}
 	
 	scanner := NewTargetScanner()
 	scanner.verifyAll = *verifyAll
@@ -668,28 +2184,519 @@ func main() {
 	scanner.debugMode = *debugMode
 	scanner.selfOnly = *selfOnly
 	scanner.timeout = *timeout
+	scanner.remoteListURL = *remoteList
+	scanner.remoteListSig = *remoteListSig
+	scanner.probeBackends = *detectBack
+	scanner.order = *order
+	scanner.perOSDeadline = *perOSDeadline
+	scanner.dumpRawDir = *dumpRaw
+	scanner.measureSize = *measureSize
+	scanner.minConfidence = *minConfidence
+	scanner.matrixFile = *matrixFile
+	scanner.probePerOS = *probePerOS
+	scanner.noPrune = *noPrune
+	scanner.trustDetection = *trustDetect
+	scanner.incremental = *incremental
+	scanner.osFilter = parseNameList(*osFilter)
+	scanner.cpuFilter = parseNameList(*cpuFilter)
+	
+	familyOSes, err := expandOSFamilies(*osFamily)
+	if err != nil {
+		log.Fatalf("Invalid --os-family: %v", err)
+	}
+	scanner.osFamilyOSes = familyOSes
+	
+	workers, ok := parseConcurrency(*concurrency)
+	if !ok {
+		log.Fatalf("Invalid --concurrency %q (want auto)", *concurrency)
+	}
+	if flagWasSet("workers") {
+		if *concurrency != "" {
+			log.Fatal("Cannot use --workers and --concurrency together")
+		}
+		if *workerCount < 1 {
+			log.Fatalf("Invalid --workers %d (must be at least 1)", *workerCount)
+		}
+		workers = *workerCount
+		if workers > maxWorkers {
+			log.Printf("Capping --workers %d at %d", workers, maxWorkers)
+			workers = maxWorkers
+		}
+	}
+	scanner.workers = workers
+	if *concurrency == "auto" {
+		log.Printf("Using %d verification workers", workers)
+	}
+	scanner.backend = *backend
+	
+	if flagWasSet("nim-path") {
+		resolved, err := exec.LookPath(*nimPath)
+		if err != nil {
+			log.Fatalf("Invalid --nim-path: %v", err)
+		}
+		scanner.nimBinary = resolved
+	}
+	scanner.RetainVerifyOutput = *keepOutput
+	scanner.VerifyOutputLimit = *outputLimit
+	
+	priority, err := parseSourcePriority(*sourcePrio)
+	if err != nil {
+		log.Fatalf("Invalid --source-priority: %v", err)
+	}
+	scanner.sourcePriority = priority
+	
+	scanner.appModes, err = parseAppModes(*appModes)
+	if err != nil {
+		log.Fatalf("Invalid --app-modes: %v", err)
+	}
+	
+	scanner.tripleFlags, err = parseTripleFlags(triples)
+	if err != nil {
+		log.Fatalf("Invalid --triple: %v", err)
+	}
+	
+	scanner.nimFlags, err = parseNimFlags(nimFlags)
+	if err != nil {
+		log.Fatalf("Invalid --nim-flag: %v", err)
+	}
+	
+	scanner.verifyEnv, err = parseVerifyEnv(verifyEnv)
+	if err != nil {
+		log.Fatalf("Invalid --verify-env: %v", err)
+	}
+	
+	scanner.noNormalize = *noNormalize
+	scanner.noBackoff = *noBackoff
+	scanner.abortAfter = *abortAfter
+	if scanner.osAliases, err = parseOSAliases(osAliasFlags); err != nil {
+		log.Fatalf("Invalid --os-alias: %v", err)
+	}
+	
+	required, err := parseRequiredTargets(requires)
+	if err != nil {
+		log.Fatalf("Invalid --require: %v", err)
+	}
+	scanner.required = make(map[[2]string]bool, len(required))
+	for _, target := range required {
+		scanner.required[target] = true
+	}
+	
+	if *osTestDir != "" {
+		if scanner.osPrograms, err = loadTestPrograms(*osTestDir); err != nil {
+			log.Fatalf("Error loading OS test programs: %v", err)
+		}
+	}
+	if *cpuTestDir != "" {
+		if scanner.cpuPrograms, err = loadTestPrograms(*cpuTestDir); err != nil {
+			log.Fatalf("Error loading CPU test programs: %v", err)
+		}
+	}
+	if *verifySource != "" {
+		source, err := os.ReadFile(*verifySource)
+		if err != nil {
+			log.Fatalf("Error reading verification source: %v", err)
+		}
+		if strings.TrimSpace(string(source)) == "" {
+			log.Fatalf("Verification source %s is empty", *verifySource)
+		}
+		scanner.defaultProgram = string(source)
+	}
+	
+	if *keepArtifacts != "" {
+		if err := os.MkdirAll(*keepArtifacts, 0o755); err != nil {
+			log.Fatalf("Error creating artifacts directory: %v", err)
+		}
+		// nim resolves --nimcache relative to the project, which is the
+		// working directory for a program read from stdin; make it explicit
+		if scanner.artifactsDir, err = filepath.Abs(*keepArtifacts); err != nil {
+			log.Fatalf("Invalid --keep-artifacts: %v", err)
+		}
+	}
+	
+	if *compatFile != "" {
+		if scanner.compat, err = loadCompatTable(*compatFile); err != nil {
+			log.Fatalf("Error loading compatibility table: %v", err)
+		}
+	}
+	if *printCompat {
+		if err := scanner.compat.write(os.Stdout); err != nil {
+			log.Fatalf("Error writing compatibility table: %v", err)
+		}
+		return
+	}
+	
+	var nameMap *NameMap
+	if *nameMapFile != "" {
+		if nameMap, err = loadNameMap(*nameMapFile); err != nil {
+			log.Fatalf("Error loading name map: %v", err)
+		}
+	}
+	
+	if *cacheReadOnly && !*useCache {
+		log.Fatal("--cache-readonly requires --cache")
+	}
+	if *remoteListSig != "" && *remoteList == "" {
+		log.Fatal("--remote-list-sig requires --remote-list")
+	}
+	if *useCache {
+		path := *cacheFile
+		if path == "" {
+			if path, err = defaultCachePath(); err != nil {
+				log.Fatalf("Error locating verification cache: %v", err)
+			}
+		}
+		if scanner.cache, err = loadVerifyCache(path, *cacheTTL); err != nil {
+			scanner.warnf("ignoring unreadable verification cache %s: %v", path, err)
+			scanner.cache = &verifyCache{path: path, ttl: *cacheTTL, entries: make(map[string]cacheEntry)}
+		}
+		scanner.cache.readOnly = *cacheReadOnly
+	}
+	
+	if *weightsFile != "" {
+		weights, err := loadComplexityWeights(*weightsFile)
+		if err != nil {
+			log.Fatalf("Error loading timeout weights: %v", err)
+		}
+		scanner.timeoutWeights = weights
+	} else if *timeoutScale {
+		scanner.timeoutWeights = defaultComplexityWeights()
+	}
+	
+	// Cancel running compiles on Ctrl-C or SIGTERM; a second signal
+	// terminates immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	
+	if command == "validate" {
+		if flag.NArg() != 2 {
+			log.Fatalf("Usage: %s validate <targets.json>", programName)
+		}
+		regressions, err := scanner.validateResultFile(ctx, os.Stdout, flag.Arg(1))
+		if err != nil {
+			log.Fatalf("Error validating %s: %v", flag.Arg(1), err)
+		}
+		if regressions > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	
+	if *explain != "" {
+		osName, cpu, err := parseTargetSpec(*explain)
+		if err != nil {
+			log.Fatalf("Invalid --explain-verification: %v", err)
+		}
+		scanner.detectNim(ctx)
+		if err := scanner.explainVerification(ctx, os.Stdout, osName, cpu); err != nil {
+			log.Fatalf("Error explaining verification: %v", err)
+		}
+		return
+	}
+	
+	if *benchmark {
+		scanner.detectNim(ctx)
+		results, err := scanner.benchmarkVerify(ctx, *benchRuns)
+		if err != nil {
+			log.Fatalf("Error benchmarking verification: %v", err)
+		}
+		if err := outputBenchmark(os.Stdout, results); err != nil {
+			log.Fatalf("Error outputting benchmark: %v", err)
+		}
+		return
+	}
+	
+	var baseline []TargetInfo
+	if *baselineFile != "" {
+		if baseline, err = loadBaseline(*baselineFile); err != nil {
+			log.Fatalf("Error loading baseline: %v", err)
+		}
+	}
+	
+	// Open the output file up front so a bad path fails before the scan
+	var out io.Writer = os.Stdout
+	var outFile *os.File
+	if *outputFile != "" {
+		if outFile, err = os.Create(*outputFile); err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		out = outFile
+	}
 	
 	// Scan for targets
-	targets := scanner.scanTargets()
+	targets := scanner.scanTargets(ctx)
+	if *onlySource != "" {
+		if targets = filterBySource(targets, *onlySource); len(targets) == 0 {
+			log.Fatalf("No %s targets found (--only-source)", *onlySource)
+		}
+	}
+	if *onlyTriple {
+		if targets = filterWithTriple(targets); len(targets) == 0 {
+			log.Fatal("No targets with a known triple found (--only-with-triple)")
+		}
+	}
+	
+	if comparedBackends != nil {
+		comparison, runs := scanner.compareBackends(ctx, targets, comparedBackends)
+		scanner.saveCache()
+		if nameMap != nil {
+			nameMap.applyComparison(&comparison)
+		}
+		err := outputBackendComparison(out, comparison, *format)
+		if outFile != nil {
+			if closeErr := outFile.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			log.Fatalf("Error outputting backend comparison: %v", err)
+		}
+		if ctx.Err() != nil {
+			os.Exit(exitInterrupted)
+		}
+		enforceExitPolicy(scanner, missingRequiredInAll(runs, required), *strictExit)
+		return
+	}
+	
+	// With --json-stream-array each target is written as soon as it is
+	// verified, filtered, redacted and renamed as it would be below
+	var stream *jsonTargetStream
+	if *streamArray {
+		stream, err = newJSONTargetStream(out, func(target *TargetInfo) bool {
+			if *verifiedOnly && !target.Verified {
+				return false
+			}
+			if *redact {
+				redactTarget(target)
+			}
+			if nameMap != nil {
+				nameMap.rename(target)
+			}
+			return true
+		})
+		if err != nil {
+			log.Fatalf("Error outputting results: %v", err)
+		}
+		scanner.OnResult = stream.OnResult
+	}
 	
 	// Verify targets
-	targets = scanner.verifyTargets(targets)
+	targets = scanner.verifyTargets(ctx, targets)
+	// The targets verification did not report are streamed afterwards;
+	// copied since the filters below reuse the slice
+	var unstreamed []TargetInfo
+	if stream != nil {
+		unstreamed = append(unstreamed, targets...)
+	}
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		log.Printf("Interrupted: writing the partial results")
+	}
+	// Redact first so every writer below, including the stderr fallback,
+	// only ever sees the redacted targets
+	if *redact {
+		redactTargets(targets)
+	}
+	// Taken before --verified-only drops the failed targets
+	var partialReport *partialOrderReport
+	if interrupted && *partialOrder {
+		reported := targets
+		if nameMap != nil {
+			reported = nameMap.renamed(targets)
+		}
+		report := newPartialOrderReport(reported)
+		partialReport = &report
+	}
+	scanner.saveCache()
+	missing := missingRequired(targets, required)
+	
+	if *verifiedOnly {
+		targets = verifiedTargets(targets)
+		if len(targets) == 0 {
+			log.Printf("Warning: --verified-only left no targets (was nim available and verification run?)")
+		}
+	}
+	
+	if *emitCfgDir != "" {
+		written, err := scanner.emitTargetConfigs(*emitCfgDir, targets)
+		if err != nil {
+			log.Fatalf("Error writing target configs: %v", err)
+		}
+		log.Printf("Wrote %d target configs to %s", written, *emitCfgDir)
+	}
+	
+	if nameMap != nil {
+		nameMap.apply(targets)
+	}
 	
 	// Output results
-	switch *format {
-	case "json":
-		if err := outputJSON(targets, scanner); err != nil {
-			log.Fatalf("Error outputting JSON: %v", err)
+	if partialReport != nil {
+		err = outputPartialOrderReport(out, *partialReport, *format)
+	} else if *groupBy != "" {
+		err = outputGrouped(out, targets, *groupBy, *format)
+	} else if *scoresOnly {
+		err = outputOSScores(out, osScores(targets), *format)
+	} else {
+		switch *format {
+		case "json":
+			if *streamArray {
+				err = stream.Finish(unstreamed)
+			} else if *compatV1 {
+				err = outputJSONCompatV1(out, targets, scanner, *wrapKey)
+			} else {
+				err = outputJSON(out, targets, scanner, *wrapKey)
+			}
+		case "cache-warm":
+			err = outputCacheWarm(out, scanner.cache)
+		case "yaml", "yaml-anchors":
+			err = outputYAML(out, targets, scanner, *format == "yaml-anchors", *wrapKey)
+		case "csv":
+			err = outputCSV(out, targets, scanner.nimVersion)
+		case "csv-long":
+			err = outputCSVLong(out, targets)
+		case "table":
+			err = outputTable(out, targets, scanner.nimVersion)
+		case "gitlab-matrix":
+			err = outputGitLabMatrix(out, targets, *matrixOSVar, *matrixCPUVar)
+		case "github-matrix":
+			err = outputGitHubMatrix(out, targets)
+		case "openmetrics":
+			err = outputOpenMetrics(out, targets, scanner)
+		case "hcl":
+			err = outputHCL(out, targets)
+		case "markdown":
+			err = outputMarkdown(out, targets)
+		case "ini":
+			err = outputINI(out, targets, scanner)
+		case "pretty":
+			err = outputPretty(out, targets, scanner, terminalWidth(out))
+		case "logfmt":
+			err = outputLogfmt(out, targets, scanner)
+		case "protobuf":
+			err = outputProtobuf(out, newTargetsResult(targets, scanner))
+		case "parquet":
+			err = outputParquet(out, targets, scanner.nimVersion)
+		case "diff-markdown":
+			err = outputDiffMarkdown(out, diffTargets(baseline, targets), *baselineFile)
+		default:
+			log.Fatalf("Unknown format: %s", *format)
+		}
+	}
+	
+	if outFile != nil {
+		if closeErr := outFile.Close(); err == nil {
+			err = closeErr
 		}
-	case "csv":
-		if err := outputCSV(targets); err != nil {
-			log.Fatalf("Error outputting CSV: %v", err)
+	}
+	
+	if fallBackOnOutputError(os.Stderr, *format, err, targets) {
+		os.Exit(1)
+	}
+	
+	if *summaryStderr {
+		if err := json.NewEncoder(os.Stderr).Encode(newTargetsResult(targets, scanner).TargetsSummary); err != nil {
+			log.Fatalf("Error writing summary: %v", err)
 		}
-	case "table":
-		if err := outputTable(targets); err != nil {
-			log.Fatalf("Error outputting table: %v", err)
+	}
+	
+	if interrupted {
+		os.Exit(exitInterrupted)
+	}
+	if scanner.verifyAborted {
+		os.Exit(1)
+	}
+	enforceExitPolicy(scanner, missing, *strictExit)
+}
+
+// enforceExitPolicy exits non-zero when a --require target is missing, or
+// with strictExit when anything was warned about. Every mode that
+// verifies targets ends with it.
+func enforceExitPolicy(scanner *TargetScanner, missing []string, strictExit bool) {
+	if len(missing) > 0 {
+		log.Printf("--require: %d required target(s) did not verify:", len(missing))
+		for _, target := range missing {
+			log.Printf("  - %s", target)
 		}
-	default:
-		log.Fatalf("Unknown format: %s", *format)
+		os.Exit(1)
 	}
+	
+	if strictExit {
+		if warnings := scanner.Warnings(); len(warnings) > 0 {
+			log.Printf("--strict-exit: failing because of %d warning(s):", len(warnings))
+			for _, warning := range warnings {
+				log.Printf("  - %s", warning)
+			}
+			os.Exit(1)
+		}
+	}
+}
+
+// verifiedTargets returns the targets that compiled, in their original
+// order.
+func verifiedTargets(targets []TargetInfo) []TargetInfo {
+	verified := targets[:0]
+	for _, target := range targets {
+		if target.Verified {
+			verified = append(verified, target)
+		}
+	}
+	return verified
+}
+
+// redactTargets blanks fields that may reveal details of the machine the
+// scan ran on, keeping the target identity and verification outcome.
+func redactTargets(targets []TargetInfo) {
+	for i := range targets {
+		redactTarget(&targets[i])
+	}
+}
+
+// redactTarget blanks the fields of one target that can carry host paths:
+// the command line and nim's own output, which names include directories,
+// nimcache and the compiler's install location.
+func redactTarget(target *TargetInfo) {
+	target.Command = ""
+	target.VerifyOutput = ""
+	target.FailReason = ""
+}
+
+// flagWasSet reports whether the named flag was given on the command line.
+func flagWasSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// fallBackOnOutputError dumps targets to w when writing them in format
+// failed with err, so the computed results are not lost just because the
+// writer failed. It reports whether err was an error.
+func fallBackOnOutputError(w io.Writer, format string, err error, targets []TargetInfo) bool {
+	if err == nil {
+		return false
+	}
+	log.Printf("Error outputting %s: %v", format, err)
+	log.Printf("Dumping %d collected targets to stderr as NDJSON", len(targets))
+	if dumpErr := dumpPartialResults(w, targets); dumpErr != nil {
+		log.Printf("Error dumping partial results: %v", dumpErr)
+	}
+	return true
+}
+
+// dumpPartialResults writes targets as newline-delimited JSON. It is the
+// fallback used when the requested output format could not be written.
+func dumpPartialResults(w io.Writer, targets []TargetInfo) error {
+	encoder := json.NewEncoder(w)
+	for _, target := range targets {
+		if err := encoder.Encode(target); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,1188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mainEnv makes the test binary run main instead of the tests, for tests
+// of the whole program: its flags, output and exit status.
+const mainEnv = "NIM_TARGETLIST_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(mainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the program with args and returns its stdout, stderr and
+// exit status. With interruptOn set, the program is sent an interrupt as
+// soon as that file exists, e.g. once a stub nim has created it.
+func runMain(t *testing.T, interruptOn string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupts and stub nims need a POSIX system")
+	}
+	var out, errOut bytes.Buffer
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), mainEnv+"=1")
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if interruptOn != "" {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			if _, err := os.Stat(interruptOn); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				cmd.Process.Kill()
+				t.Fatalf("%s never appeared", interruptOn)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cmd.Process.Signal(os.Interrupt)
+	}
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), cmd.ProcessState.ExitCode()
+}
+
+// writeStubNim writes script as an executable stub nim for runMain.
+func writeStubNim(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nim")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// stubNimScanner returns a scanner that runs script, a POSIX shell script
+// standing in for nim, instead of a real installation.
+func stubNimScanner(t testing.TB, script string) *TargetScanner {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub nim is a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "nim")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ts := NewTargetScanner()
+	ts.nimBinary = path
+	ts.nimAvailable = true
+	ts.timeout = 10 * time.Second
+	return ts
+}
+
+// recordingNimScanner is stubNimScanner with a stub that first logs its
+// arguments, one line per run. calls returns the lines logged so far.
+func recordingNimScanner(t testing.TB, script string) (ts *TargetScanner, calls func() []string) {
+	t.Helper()
+	log := filepath.Join(t.TempDir(), "calls")
+	ts = stubNimScanner(t, fmt.Sprintf("echo \"$*\" >>'%s'\n%s", log, script))
+	return ts, func() []string {
+		data, err := os.ReadFile(log)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+}
+
+func TestRedactTargets(t *testing.T) {
+	targets := []TargetInfo{{
+		OS:           "linux",
+		CPU:          "arm64",
+		Verified:     false,
+		VerifyStatus: verifyStatusFailed,
+		Command:      "/home/me/.nimble/bin/nim c --os:linux --cpu:arm64",
+		VerifyOutput: "/home/me/.choosenim/toolchains/nim-2.0.2/lib/system.nim(1, 1) Error: boom",
+		FailReason:   "/home/me/.choosenim/toolchains/nim-2.0.2/lib/system.nim(1, 1) Error: boom",
+	}}
+	redactTargets(targets)
+
+	want := TargetInfo{OS: "linux", CPU: "arm64", Verified: false, VerifyStatus: verifyStatusFailed}
+	if !reflect.DeepEqual(targets[0], want) {
+		t.Errorf("redactTargets left %+v, want %+v", targets[0], want)
+	}
+}
+
+func TestProbePseudoOSesMarksRejectedUnusable(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+for arg; do
+	if [ "$arg" = --os:any ]; then echo "Error: system module needs: panicoverride"; exit 1; fi
+done
+`)
+	got := ts.probePseudoOSes(context.Background(), []string{"linux", "any", "standalone"})
+	if want := map[string]bool{"any": false, "standalone": true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("probePseudoOSes() = %v, want %v", got, want)
+	}
+}
+
+func TestProbePseudoOSesCancelled(t *testing.T) {
+	ts := stubNimScanner(t, "cat >/dev/null\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := ts.probePseudoOSes(ctx, []string{"any"}); got != nil {
+		t.Errorf("probePseudoOSes() with a cancelled context = %v, want nil", got)
+	}
+}
+
+func TestParseNimVersion(t *testing.T) {
+	tests := []struct {
+		output, want string
+	}{
+		{"Nim Compiler Version 2.0.2 [Linux: amd64]\nCompiled at 2023-12-15\n", "2.0.2"},
+		{"Nim Compiler Version 1.6.14 [Windows: i386]", "1.6.14"},
+		{"nim compiler version 2.2.0 [MacOSX: arm64]", "2.2.0"},
+		{"Hint: used config file '/etc/nim/nim.cfg'\nNim Compiler Version 2.1.99 [Linux: riscv64]", "2.1.99"},
+		{"nim: command not found", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := parseNimVersion(test.output); got != test.want {
+			t.Errorf("parseNimVersion(%q) = %q, want %q", test.output, got, test.want)
+		}
+	}
+}
+
+func TestExtractTargetsStripsParenthesized(t *testing.T) {
+	ts := NewTargetScanner()
+	got := ts.extractTargetsFromString("linux (GNU/Linux), macosx (darwin), amd64 (x86_64), windows, freebsd")
+	if want := []string{"linux", "macosx", "amd64", "windows", "freebsd"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("extractTargetsFromString() = %q, want %q", got, want)
+	}
+	wantAliases := map[string]string{"darwin": "macosx", "x86_64": "amd64"}
+	if !reflect.DeepEqual(ts.detectedAliases, wantAliases) {
+		t.Errorf("detectedAliases = %v, want %v", ts.detectedAliases, wantAliases)
+	}
+}
+
+func TestExtractTargetsTrimsPunctuation(t *testing.T) {
+	ts := NewTargetScanner()
+	for _, input := range []string{
+		"i386, arm, arm64 and amd64.",
+		"i386; arm; \"arm64\"; amd64!",
+		"i386 arm [arm64] amd64.",
+	} {
+		got := ts.extractTargetsFromString(input)
+		if want := []string{"i386", "arm", "arm64", "amd64"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("extractTargetsFromString(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// verifyTargetsPerGoroutine is how --verify-all used to run before the
+// worker pool: one goroutine per target, bounded by a semaphore. It is
+// kept as the reference the pool must match.
+func verifyTargetsPerGoroutine(ts *TargetScanner, targets []TargetInfo) []TargetInfo {
+	defer markSkipped(targets)
+	semaphore := make(chan struct{}, defaultWorkers)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			ts.verifyWithBudget(context.Background(), &targets[idx], nil)
+		}(i)
+	}
+	wg.Wait()
+	return targets
+}
+
+// poolNim rejects dos and avr, and takes a little longer for some CPUs so
+// that compiles finish out of order.
+const poolNim = `cat >/dev/null
+for arg; do
+	case "$arg" in
+	--os:dos|--cpu:avr) echo "Error: unsupported"; exit 1;;
+	--cpu:arm*|--cpu:mips*) sleep 0.02;;
+	esac
+done
+`
+
+func poolTargets() []TargetInfo {
+	var targets []TargetInfo
+	for _, osName := range []string{"linux", "windows", "macosx", "freebsd", "dos", "netbsd"} {
+		for _, cpu := range []string{"amd64", "i386", "arm", "arm64", "avr", "mips", "mipsel", "riscv64"} {
+			targets = append(targets, TargetInfo{OS: osName, CPU: cpu, Backend: "c"})
+		}
+	}
+	return targets
+}
+
+func TestVerifyTargetsPoolMatchesPerGoroutine(t *testing.T) {
+	ts := stubNimScanner(t, poolNim)
+	ts.verifyAll = true
+
+	var progress []int
+	ts.OnProgress = func(done, total int) { progress = append(progress, done) }
+	got := ts.verifyTargets(context.Background(), poolTargets())
+
+	ts.OnProgress = nil
+	want := verifyTargetsPerGoroutine(ts, poolTargets())
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("worker pool results differ from one goroutine per target:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	// Progress counts completions, whatever order the targets finish in
+	for i, done := range progress {
+		if done != i+1 {
+			t.Fatalf("progress went %v, want 1 to %d in steps of one", progress, len(got))
+		}
+	}
+	if len(progress) != len(got) {
+		t.Errorf("%d progress reports for %d targets", len(progress), len(got))
+	}
+}
+
+func BenchmarkVerifyTargetsPool(b *testing.B) {
+	ts := stubNimScanner(b, "cat >/dev/null\n")
+	ts.verifyAll = true
+	for i := 0; i < b.N; i++ {
+		ts.verifyTargets(context.Background(), poolTargets())
+	}
+}
+
+func BenchmarkVerifyTargetsPerGoroutine(b *testing.B) {
+	ts := stubNimScanner(b, "cat >/dev/null\n")
+	ts.verifyAll = true
+	for i := 0; i < b.N; i++ {
+		verifyTargetsPerGoroutine(ts, poolTargets())
+	}
+}
+
+func TestOutputJSONWrapKey(t *testing.T) {
+	targets, scanner := sampleTargets()
+	var buf bytes.Buffer
+	if err := outputJSON(&buf, targets, scanner, "nim_targets"); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]TargetsResult
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	result, ok := doc["nim_targets"]
+	if len(doc) != 1 || !ok {
+		t.Fatalf("want only the nim_targets key:\n%s", buf.String())
+	}
+	if result.TotalCount != len(targets) || len(result.Targets) != len(targets) {
+		t.Errorf("wrapped result has %d targets, want %d", len(result.Targets), len(targets))
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		reports []string
+		wantErr string
+	}{
+		{"json", nil, ""},
+		{"yaml-anchors", nil, ""},
+		{"table", []string{"--group-by", "--os-scores"}, ""},
+		{"xml", nil, `unknown --format "xml"`},
+		{"yaml", []string{"--compare-backends"}, "--compare-backends supports only --format json, csv, table"},
+		{"csv", []string{"--os-scores"}, "--os-scores supports only --format json, table"},
+		{"pretty", []string{"--group-by"}, "--group-by supports only"},
+		{"markdown", []string{"--verify-partial-order-report"}, "--verify-partial-order-report supports only"},
+	}
+	for _, tt := range tests {
+		err := checkFormat(tt.format, tt.reports)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("checkFormat(%q, %q) = %v, want nil", tt.format, tt.reports, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("checkFormat(%q, %q) = %v, want an error containing %q", tt.format, tt.reports, err, tt.wantErr)
+		}
+	}
+}
+
+// Every report format must be one the report's writer handles
+func TestReportFormatsAreWritten(t *testing.T) {
+	for report, formats := range reportFormats {
+		for _, format := range formats {
+			var err error
+			switch report {
+			case "--group-by":
+				err = outputGrouped(io.Discard, nil, "os", format)
+			case "--compare-backends":
+				err = outputBackendComparison(io.Discard, BackendComparison{}, format)
+			case "--os-scores":
+				err = outputOSScores(io.Discard, nil, format)
+			case "--verify-partial-order-report":
+				err = outputPartialOrderReport(io.Discard, partialOrderReport{}, format)
+			default:
+				t.Fatalf("no writer known for %s", report)
+			}
+			if err != nil {
+				t.Errorf("%s --format %s: %v", report, format, err)
+			}
+		}
+	}
+}
+
+// A known_invalid entry the compatibility table already prunes is dead
+func TestKnownInvalidTargetsNotPruned(t *testing.T) {
+	compat := defaultCompatTable()
+	for pair := range knownInvalidTargets {
+		osName, cpu, _ := strings.Cut(pair, "/")
+		if !compat.compatible(osName, cpu) {
+			t.Errorf("%s is in knownInvalidTargets but already pruned by the compatibility table", pair)
+		}
+	}
+}
+
+func TestVerifyTargetSharesConcurrentDuplicates(t *testing.T) {
+	ts, calls := recordingNimScanner(t, "cat >/dev/null\nsleep 0.3\n")
+	var wg sync.WaitGroup
+	results := make([]verifyResult, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = ts.verifyTarget(context.Background(), "linux", "amd64", "c")
+		}(i)
+	}
+	wg.Wait()
+
+	if n := len(calls()); n != 1 {
+		t.Errorf("nim ran %d times for 8 concurrent requests of one target, want once", n)
+	}
+	for i, result := range results {
+		if !result.verified {
+			t.Errorf("request %d: %+v, want verified", i, result)
+		}
+	}
+
+	// Once finished, the same target is compiled afresh
+	ts.verifyTarget(context.Background(), "linux", "amd64", "c")
+	if n := len(calls()); n != 2 {
+		t.Errorf("nim ran %d times in total, want 2", n)
+	}
+}
+
+// failingWriter accepts n bytes and then fails every write.
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		written := w.n
+		w.n = 0
+		return written, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestOutputErrorDumpsNDJSON(t *testing.T) {
+	targets, scanner := sampleTargets()
+	err := outputJSON(&failingWriter{n: 16}, targets, scanner, "")
+	if err == nil {
+		t.Fatal("outputJSON() succeeded on a failing writer")
+	}
+
+	var stderr bytes.Buffer
+	if !fallBackOnOutputError(&stderr, "json", err, targets) {
+		t.Fatal("fallBackOnOutputError() ignored the error")
+	}
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	if len(lines) != len(targets) {
+		t.Fatalf("dumped %d lines for %d targets:\n%s", len(lines), len(targets), stderr.String())
+	}
+	for i, line := range lines {
+		var got TargetInfo
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, targets[i]) {
+			t.Errorf("line %d = %+v, want %+v", i+1, got, targets[i])
+		}
+	}
+
+	stderr.Reset()
+	if fallBackOnOutputError(&stderr, "json", nil, targets) || stderr.Len() != 0 {
+		t.Error("fallback used without an error")
+	}
+}
+
+func TestTargetCommandQuotesMaliciousNames(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("checks the quoting with sh")
+	}
+	ts := NewTargetScanner()
+	for _, osName := range []string{"linux; rm -rf ~", "$(reboot)", "a'b", "`id`\n"} {
+		command := ts.targetCommand("c", osName, "amd64")
+		// Run the command with printf in place of nim to see the words a
+		// shell splits it into
+		out, err := exec.Command("sh", "-c", `printf '%s\n' `+strings.TrimPrefix(command, "nim ")).Output()
+		if err != nil {
+			t.Fatalf("%q: %v", command, err)
+		}
+		want := "c\n--os:" + osName + "\n--cpu:amd64\n"
+		if string(out) != want {
+			t.Errorf("%q splits into %q, want %q", command, out, want)
+		}
+	}
+}
+
+func TestMaliciousNamesRejected(t *testing.T) {
+	ts := NewTargetScanner()
+	for _, name := range []string{"linux; rm -rf ~", "$(reboot)", "a'b", "../evil", "Linux"} {
+		if ts.isValidTargetName(name, "os") || ts.isValidTargetName(name, "cpu") {
+			t.Errorf("isValidTargetName(%q) accepted it", name)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "matrix.json")
+	if err := os.WriteFile(path, []byte(`[{"os": "linux;reboot", "cpu": "amd64"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if targets, err := ts.loadMatrixFile(path); err == nil {
+		t.Errorf("loadMatrixFile() accepted a malicious name: %+v", targets)
+	}
+}
+
+func TestVerifyTargetPassesVerifyEnv(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+echo "CC=$CC AR=$AR"
+[ "$CC" = arm-linux-gnueabihf-gcc ]
+`)
+	ts.RetainVerifyOutput = true
+	if result := ts.verifyTarget(context.Background(), "linux", "arm", "c"); result.verified {
+		t.Errorf("verified without --verify-env: %+v", result)
+	}
+
+	ts.verifyEnv = []string{"CC=arm-linux-gnueabihf-gcc", "AR=arm-linux-gnueabihf-ar"}
+	result := ts.verifyTarget(context.Background(), "linux", "arm", "c")
+	if !result.verified {
+		t.Errorf("not verified with --verify-env: %+v", result)
+	}
+	if want := "CC=arm-linux-gnueabihf-gcc AR=arm-linux-gnueabihf-ar"; !strings.Contains(result.output, want) {
+		t.Errorf("nim saw %q, want %q", result.output, want)
+	}
+	if os.Getenv("CC") == "arm-linux-gnueabihf-gcc" {
+		t.Error("--verify-env leaked into our own environment")
+	}
+}
+
+func TestVerifyStatusAcrossModes(t *testing.T) {
+	const nim = `cat >/dev/null
+case "$*" in *--cpu:arm64*) echo "Error: unhandled exception"; exit 1 ;; esac
+`
+	targets := func() []TargetInfo {
+		return []TargetInfo{
+			{OS: "linux", CPU: "amd64", Backend: "c"},
+			{OS: "linux", CPU: "arm64", Backend: "c"},
+			{OS: "linux", CPU: "mips", Backend: "c"},
+		}
+	}
+	tests := []struct {
+		name       string
+		skipVerify bool
+		verifyAll  bool
+		want       []string
+	}{
+		{"skip-verify", true, false, []string{verifyStatusSkipped, verifyStatusSkipped, verifyStatusSkipped}},
+		{"default", false, false, []string{verifyStatusVerified, verifyStatusFailed, verifyStatusSkipped}},
+		{"verify-all", false, true, []string{verifyStatusVerified, verifyStatusFailed, verifyStatusVerified}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := stubNimScanner(t, nim)
+			ts.skipVerify = tt.skipVerify
+			ts.verifyAll = tt.verifyAll
+			got := ts.verifyTargets(context.Background(), targets())
+			for i, target := range got {
+				if target.VerifyStatus != tt.want[i] {
+					t.Errorf("%s/%s: status %q, want %q", target.OS, target.CPU, target.VerifyStatus, tt.want[i])
+				}
+				if target.Verified != (tt.want[i] == verifyStatusVerified) {
+					t.Errorf("%s/%s: Verified %v with status %q", target.OS, target.CPU, target.Verified, target.VerifyStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultThreadsFor(t *testing.T) {
+	tests := []struct {
+		output  string
+		threads bool
+	}{
+		{"Nim Compiler Version 1.6.14 [Linux: amd64]", false},
+		{"Nim Compiler Version 1.0.0 [Windows: i386]", false},
+		{"Nim Compiler Version 2.0.2 [Linux: amd64]", true},
+		{"Nim Compiler Version 2.2.0 [MacOSX: arm64]", true},
+		{"nim compiler version 10.1.0", true},
+		{"Nim Compiler Version devel", false},
+	}
+	for _, tt := range tests {
+		if got := defaultThreadsFor(parseNimVersion(tt.output)); got != tt.threads {
+			t.Errorf("%q: default threads %v, want %v", tt.output, got, tt.threads)
+		}
+	}
+}
+
+func TestThreadsOffFollowsNimDefault(t *testing.T) {
+	for version, threadsOff := range map[string]bool{"1.6.14": false, "2.0.2": true} {
+		ts := stubNimScanner(t, `echo "Nim Compiler Version `+version+` [Linux: amd64]"`+"\n")
+		ts.detectNim(context.Background())
+		if ts.nimVersion != version {
+			t.Fatalf("detected version %q, want %s", ts.nimVersion, version)
+		}
+		for _, target := range [][2]string{{"standalone", "avr"}, {"linux", "amd64"}} {
+			got := strings.Contains(strings.Join(ts.verifyArgs(target[0], target[1]), " "), "--threads:off")
+			want := threadsOff && target[0] == "standalone"
+			if got != want {
+				t.Errorf("nim %s, %s/%s: --threads:off passed %v, want %v", version, target[0], target[1], got, want)
+			}
+		}
+	}
+}
+
+func TestExplainVerification(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+case "$*" in
+*--cpu:arm64*) echo "Error: cannot open file"; exit 3 ;;
+*) echo "Warning: macosx is deprecated" ;;
+esac
+`)
+	var buf bytes.Buffer
+	if err := ts.explainVerification(context.Background(), &buf, "linux", "amd64"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Target:   linux/amd64\n",
+		"Command:  " + ts.nimBinary + " --os:linux --cpu:amd64 --compileOnly --hints:off --warnings:off c -\n",
+		"Timeout:  10s\n",
+		"Stdin:\n" + strings.TrimRight(ts.testProgram("linux", "amd64"), "\n") + "\n",
+		"Output:\nWarning: macosx is deprecated\n",
+		"Exit code: 0\n",
+		"Verified: true\n",
+		"Deprecated: true\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("explanation lacks %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	if err := ts.explainVerification(context.Background(), &buf, "linux", "arm64"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Exit code: 3\n", "Verified: false\n", "Deprecated: false\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("failed explanation lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	ts.nimAvailable = false
+	if err := ts.explainVerification(context.Background(), &buf, "linux", "amd64"); err == nil {
+		t.Error("no error without nim")
+	}
+}
+
+// scanHardcoded scans the built-in lists alone, without nim, and indexes
+// the targets by os/cpu.
+func scanHardcoded(t *testing.T, ts *TargetScanner) map[string]TargetInfo {
+	t.Helper()
+	ts.hardcodedOnly = true
+	byName := make(map[string]TargetInfo)
+	for _, target := range ts.scanTargets(context.Background()) {
+		byName[target.OS+"/"+target.CPU] = target
+	}
+	if len(byName) == 0 {
+		t.Fatal("no hardcoded targets")
+	}
+	return byName
+}
+
+func TestCrossOnlyTargets(t *testing.T) {
+	targets := scanHardcoded(t, NewTargetScanner())
+	for name, crossOnly := range map[string]bool{
+		"standalone/avr":    true,
+		"standalone/msp430": true,
+		"js/js":             true,
+		"linux/amd64":       false,
+		"windows/arm64":     false,
+	} {
+		target, ok := targets[name]
+		if !ok {
+			t.Errorf("%s not scanned", name)
+			continue
+		}
+		if target.CrossOnly != crossOnly {
+			t.Errorf("%s: cross_only %v, want %v", name, target.CrossOnly, crossOnly)
+		}
+	}
+	for name, target := range targets {
+		if target.CrossOnly != crossOnlyCPUs[target.CPU] {
+			t.Errorf("%s: cross_only %v disagrees with its CPU", name, target.CrossOnly)
+		}
+	}
+}
+
+func TestParseSourcePriority(t *testing.T) {
+	got, err := parseSourcePriority(" Hardcoded, external,detected")
+	if want := []string{"hardcoded", "external", "detected"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSourcePriority() = %v, %v, want %v", got, err, want)
+	}
+	for value, want := range map[string]string{
+		"detected,external":          "must list all sources",
+		"detected,external,detected": "listed more than once",
+		"detected,external,guessed":  `unknown source "guessed"`,
+		"":                           `unknown source ""`,
+	} {
+		if _, err := parseSourcePriority(value); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestTripleFlags(t *testing.T) {
+	triples, err := parseTripleFlags([]string{
+		"linux/arm=--gcc.exe:arm-linux-gnueabihf-gcc",
+		"linux/arm=-d:armv7  --passC:-march=armv7-a",
+		"windows/amd64=-d:mingw",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"linux/arm":     {"--gcc.exe:arm-linux-gnueabihf-gcc", "-d:armv7", "--passC:-march=armv7-a"},
+		"windows/amd64": {"-d:mingw"},
+	}
+	if !reflect.DeepEqual(triples, want) {
+		t.Errorf("parseTripleFlags() = %q, want %q", triples, want)
+	}
+	for _, bad := range []string{"linux/arm", "linux=-d:x"} {
+		if _, err := parseTripleFlags([]string{bad}); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+
+	ts, calls := recordingNimScanner(t, "cat >/dev/null\n")
+	ts.tripleFlags = triples
+	ts.verifyTarget(context.Background(), "linux", "arm", "c")
+	ts.verifyTarget(context.Background(), "linux", "arm64", "c")
+	wantCalls := []string{
+		"--os:linux --cpu:arm --compileOnly --hints:off --warnings:off --gcc.exe:arm-linux-gnueabihf-gcc -d:armv7 --passC:-march=armv7-a c -",
+		"--os:linux --cpu:arm64 --compileOnly --hints:off --warnings:off c -",
+	}
+	if got := calls(); !reflect.DeepEqual(got, wantCalls) {
+		t.Errorf("nim ran with\n%q\nwant\n%q", got, wantCalls)
+	}
+	if got := ts.targetCommand("c", "windows", "amd64"); got != "nim c --os:windows --cpu:amd64 -d:mingw" {
+		t.Errorf("targetCommand() = %q", got)
+	}
+}
+
+func TestSortByRank(t *testing.T) {
+	names := []string{"aix", "arm", "haiku", "linux", "windows", "zephyr"}
+	sortByRank(names, []string{"windows", "linux", "macosx"})
+	if want := []string{"windows", "linux", "aix", "arm", "haiku", "zephyr"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sortByRank() = %v, want %v", names, want)
+	}
+}
+
+func TestPopularityOrder(t *testing.T) {
+	ts := NewTargetScanner()
+	ts.hardcodedOnly = true
+	ts.order = "popularity"
+	targets := ts.scanTargets(context.Background())
+	if first := targets[0]; first.OS != "linux" || first.CPU != "amd64" {
+		t.Errorf("first target %s/%s, want linux/amd64", first.OS, first.CPU)
+	}
+	var oses []string
+	for _, target := range targets {
+		if len(oses) == 0 || oses[len(oses)-1] != target.OS {
+			oses = append(oses, target.OS)
+		}
+	}
+	if !reflect.DeepEqual(oses[:len(popularOSes)], popularOSes) {
+		t.Errorf("OSes start %v, want %v", oses[:len(popularOSes)], popularOSes)
+	}
+	rest := oses[len(popularOSes):]
+	if !sort.StringsAreSorted(rest) {
+		t.Errorf("unranked OSes are not alphabetical: %v", rest)
+	}
+}
+
+func TestDumpRawArchivesEveryQuery(t *testing.T) {
+	ts := stubNimScanner(t, `echo "nothing to see: $*"`+"\n")
+	ts.dumpRawDir = filepath.Join(t.TempDir(), "raw", "nested")
+	if oses, _ := ts.tryNimQuery(context.Background(), "os"); oses != nil {
+		t.Fatalf("tryNimQuery() parsed %v from the stub", oses)
+	}
+
+	entries, err := os.ReadDir(ts.dumpRawDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{
+		"os-00_--os_invalid_c.txt",
+		"os-01_--os_help_c.txt",
+		"os-02_--os__c.txt",
+		"os-03_--help.txt",
+		"os-04_-h.txt",
+		"os-05_help.txt",
+		"os-06_--version.txt",
+		"os-07_-v.txt",
+		"os-08_dump_--dump.format_json_dummy.txt",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("dumped %q, want %q", names, want)
+	}
+	data, err := os.ReadFile(filepath.Join(ts.dumpRawDir, "os-02_--os__c.txt"))
+	if err != nil || string(data) != "nothing to see: --os:? c\n" {
+		t.Errorf("raw output %q, %v", data, err)
+	}
+
+	// A directory that cannot be created is warned about
+	ts.dumpRawDir = filepath.Join(ts.dumpRawDir, "os-05_help.txt", "sub")
+	ts.dumpRaw("cpu-00", []string{"--help"}, []byte("x"))
+	if warnings := ts.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "cannot create raw output directory") {
+		t.Errorf("warnings = %q", warnings)
+	}
+}
+
+func TestCleanTerminalOutput(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"\x1b[1;31mError:\x1b[0m unknown OS", "Error: unknown OS"},
+		{"\x1b]0;nim\x07title\x1b]8;;http://x\x1b\\link", "titlelink"},
+		{"_\bl_\bi_\bn_\bu_\bx and w\bwi\bin", "linux and win"},
+		{"line one\r\nline two", "line one\nline two"},
+		{"progress 10%\rprogress 100%", "progress 100%"},
+		{"linux\n--More--(50%)\nwindows\n(END)\n:\nlines 1-20/40 50%", "linux\nwindows"},
+	}
+	for _, tt := range tests {
+		if got := cleanTerminalOutput(tt.in); got != tt.want {
+			t.Errorf("cleanTerminalOutput(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	plain := "Error: unknown OS: 'invalid'. Available options are: linux, windows, macosx, freebsd\n"
+	colored := "\x1b[31mError:\x1b[0m unknown OS: 'invalid'. \x1b[1mAvailable options are:\x1b[0m l\bli\bin\bnu\bux\bx, windows, macosx, freebsd\n--More--\n"
+	ts := NewTargetScanner()
+	want, _ := ts.parseHelpOutput(plain, "os")
+	got, _ := ts.parseHelpOutput(colored, "os")
+	if len(want) == 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("colored help parsed to %v, plain to %v", got, want)
+	}
+}
+
+func TestRuntimeWarningOnlyForVerifiedTargets(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+case "$*" in *--cpu:msp430*) echo "Error: no toolchain"; exit 1 ;; esac
+`)
+	ts.verifyAll = true
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: "standalone", CPU: "wasm32"},
+		{OS: "standalone", CPU: "msp430"},
+		{OS: "linux", CPU: "amd64"},
+	})
+	want := []string{runtimeRequirements["wasm32"], "", ""}
+	for i, target := range targets {
+		if target.RuntimeWarning != want[i] {
+			t.Errorf("%s/%s: runtime warning %q, want %q", target.OS, target.CPU, target.RuntimeWarning, want[i])
+		}
+	}
+}
+
+func TestDetectionConfidence(t *testing.T) {
+	ts := NewTargetScanner()
+	_, confidence := ts.parseHelpOutput(strings.Join([]string{
+		"available options are: linux, redox, kolibri",
+		"haiku serenity toaru linux",
+	}, "\n"), "os")
+	want := map[string]float64{
+		"linux":    1.0, // the phrase match plus a known name, capped
+		"redox":    phraseMatchConfidence,
+		"haiku":    genericMatchConfidence + knownNameBonus,
+		"serenity": genericMatchConfidence,
+	}
+	for name, score := range want {
+		if got, ok := confidence[name]; !ok || math.Abs(got-score) > 1e-9 {
+			t.Errorf("%s: confidence %v (found %v), want %v", name, got, ok, score)
+		}
+	}
+}
+
+func TestMinConfidenceExcludesWeakDetections(t *testing.T) {
+	ts := stubNimScanner(t, `case "$*" in
+--version) echo "Nim Compiler Version 2.0.2" ;;
+--os:invalid*) echo "available options are: linux, redox, kolibri"; exit 1 ;;
+--cpu:invalid*) echo "available options are: amd64, arm64, i386"; exit 1 ;;
+*) exit 1 ;;
+esac
+`)
+	ts.skipVerify = true
+	ts.osFilter = []string{"linux", "redox"}
+	ts.cpuFilter = []string{"amd64"}
+	scan := func(min float64) map[string]float64 {
+		ts.minConfidence = min
+		got := make(map[string]float64)
+		for _, target := range ts.scanTargets(context.Background()) {
+			got[target.OS+"/"+target.CPU] = target.Confidence
+		}
+		return got
+	}
+
+	want := map[string]float64{"linux/amd64": 1.0, "redox/amd64": phraseMatchConfidence}
+	if got := scan(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("targets %v, want %v", got, want)
+	}
+	if got := scan(0.9); !reflect.DeepEqual(got, map[string]float64{"linux/amd64": 1.0}) {
+		t.Errorf("with --min-confidence 0.9: targets %v, want only linux/amd64", got)
+	}
+}
+
+func TestKnownInvalidTargetsAreNeverCompiled(t *testing.T) {
+	scanned := scanHardcoded(t, NewTargetScanner())
+	var targets []TargetInfo
+	for _, name := range []string{"ios/i386", "linux/amd64", "macosx/powerpc"} {
+		target, ok := scanned[name]
+		if !ok {
+			t.Fatalf("%s not scanned", name)
+		}
+		targets = append(targets, target)
+	}
+
+	ts, calls := recordingNimScanner(t, "cat >/dev/null\n")
+	ts.verifyAll = true
+	targets = ts.verifyTargets(context.Background(), targets)
+	for _, target := range targets {
+		invalid := knownInvalidTargets[target.OS+"/"+target.CPU]
+		if invalid && (target.SkipReason != "known_invalid" || target.VerifyStatus != verifyStatusSkipped) {
+			t.Errorf("%s/%s: skip reason %q, status %q", target.OS, target.CPU, target.SkipReason, target.VerifyStatus)
+		}
+		if !invalid && target.VerifyStatus != verifyStatusVerified {
+			t.Errorf("%s/%s: status %q, want verified", target.OS, target.CPU, target.VerifyStatus)
+		}
+	}
+	if got := calls(); len(got) != 1 || !strings.HasPrefix(got[0], "--os:linux --cpu:amd64 ") {
+		t.Errorf("nim ran with %q, want only linux/amd64", got)
+	}
+}
+
+func TestOnResultReportsEachTargetOnce(t *testing.T) {
+	ts := stubNimScanner(t, poolNim)
+	ts.verifyAll = true
+	ts.workers = 8
+
+	var running int32
+	reported := make(map[string]TargetInfo)
+	var events []string
+	ts.OnResult = func(target TargetInfo) {
+		if atomic.AddInt32(&running, 1) != 1 {
+			t.Error("callbacks ran concurrently")
+		}
+		time.Sleep(time.Millisecond)
+		key := target.OS + "/" + target.CPU
+		if _, dup := reported[key]; dup {
+			t.Errorf("%s reported twice", key)
+		}
+		reported[key] = target
+		events = append(events, "result")
+		atomic.AddInt32(&running, -1)
+	}
+	ts.OnProgress = func(done, total int) { events = append(events, "progress") }
+	targets := ts.verifyTargets(context.Background(), poolTargets())
+
+	if len(reported) != len(targets) {
+		t.Fatalf("%d of %d targets reported", len(reported), len(targets))
+	}
+	for _, target := range targets {
+		if got := reported[target.OS+"/"+target.CPU]; !reflect.DeepEqual(got, target) {
+			t.Errorf("reported %+v, final result %+v", got, target)
+		}
+	}
+	for i := 0; i < len(events); i += 2 {
+		if events[i] != "result" || events[i+1] != "progress" {
+			t.Fatalf("callbacks ran in the order %v, want each result before its progress", events[i:i+2])
+		}
+	}
+}
+
+func TestAppModes(t *testing.T) {
+	modes, err := parseAppModes(" Lib, ,staticlib")
+	if err != nil || !reflect.DeepEqual(modes, []string{"lib", "staticlib"}) {
+		t.Fatalf("parseAppModes() = %v, %v", modes, err)
+	}
+	if _, err := parseAppModes("lib,dll"); err == nil || !strings.Contains(err.Error(), `"dll"`) {
+		t.Errorf("unknown mode: error %v", err)
+	}
+
+	// Static libraries fail on arm64; windows/i386 fails outright
+	ts, calls := recordingNimScanner(t, `cat >/dev/null
+case "$*" in
+*--cpu:i386*) exit 1 ;;
+*--cpu:arm64*--app:staticlib*) exit 1 ;;
+esac
+`)
+	ts.verifyAll = true
+	ts.appModes = modes
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: "linux", CPU: "arm64", Backend: "c"},
+		{OS: "windows", CPU: "i386", Backend: "c"},
+	})
+
+	if want := map[string]bool{"lib": true, "staticlib": false}; !reflect.DeepEqual(targets[0].AppModes, want) {
+		t.Errorf("linux/arm64 app modes %v, want %v", targets[0].AppModes, want)
+	}
+	if targets[1].AppModes != nil {
+		t.Errorf("unverified windows/i386 has app modes %v", targets[1].AppModes)
+	}
+	var libCalls []string
+	for _, call := range calls() {
+		if strings.Contains(call, "--app:") {
+			libCalls = append(libCalls, call)
+		}
+	}
+	want := []string{
+		"--os:linux --cpu:arm64 --compileOnly --hints:off --warnings:off --app:lib c -",
+		"--os:linux --cpu:arm64 --compileOnly --hints:off --warnings:off --app:staticlib c -",
+	}
+	if !reflect.DeepEqual(libCalls, want) {
+		t.Errorf("library compiles %q, want %q", libCalls, want)
+	}
+}
+
+func TestDeprecatedTargets(t *testing.T) {
+	for output, want := range map[string]bool{
+		"Warning: macosx/powerpc is DEPRECATED": true,
+		"Error: option 'deprecated' removed":    true,
+		"undeprecatedSymbol used":               false,
+		"":                                      false,
+	} {
+		if got := deprecationNotice([]byte(output)); got != want {
+			t.Errorf("deprecationNotice(%q) = %v, want %v", output, got, want)
+		}
+	}
+
+	ts := stubNimScanner(t, `cat >/dev/null
+case "$*" in *--os:dos*) echo "Warning: dos is deprecated [Deprecated]" ;; esac
+`)
+	ts.verifyAll = true
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: "dos", CPU: "i386"},
+		{OS: "linux", CPU: "i386"},
+	})
+	if !targets[0].Verified || !targets[0].Deprecated {
+		t.Errorf("dos/i386: verified %v, deprecated %v; want both", targets[0].Verified, targets[0].Deprecated)
+	}
+	if targets[1].Deprecated {
+		t.Error("linux/i386 flagged deprecated")
+	}
+	data, err := json.Marshal(targets[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "deprecated") {
+		t.Errorf("deprecated is not omitted when false: %s", data)
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		output string
+		limit  int
+		want   string
+	}{
+		{"short", 10, "short"},
+		{"anything", 0, "anything"},
+		{"line 1\nError: boom", 11, "[truncated 7 bytes]\nError: boom"},
+		// The cut would split "é", so the whole character goes
+		{"abcé!", 2, "[truncated 5 bytes]\n!"},
+	}
+	for _, tt := range tests {
+		if got := truncateOutput(tt.output, tt.limit); got != tt.want {
+			t.Errorf("truncateOutput(%q, %d) = %q, want %q", tt.output, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRetainedVerifyOutput(t *testing.T) {
+	ts := stubNimScanner(t, `cat >/dev/null
+echo "Hint: compiling"
+echo "Error: type mismatch"; exit 1
+`)
+	ts.verifyAll = true
+	verify := func() TargetInfo {
+		return ts.verifyTargets(context.Background(), []TargetInfo{{OS: "linux", CPU: "amd64"}})[0]
+	}
+	if target := verify(); target.VerifyOutput != "" {
+		t.Errorf("output retained without RetainVerifyOutput: %q", target.VerifyOutput)
+	}
+
+	ts.RetainVerifyOutput = true
+	if target := verify(); target.VerifyOutput != "Hint: compiling\nError: type mismatch\n" {
+		t.Errorf("retained output %q", target.VerifyOutput)
+	}
+	ts.VerifyOutputLimit = 21
+	if target := verify(); target.VerifyOutput != "[truncated 16 bytes]\nError: type mismatch\n" {
+		t.Errorf("truncated output %q", target.VerifyOutput)
+	}
+}
+
+func TestSummaryJSONToStderr(t *testing.T) {
+	stdout, stderr, code := runMain(t, "", "--hardcoded-only", "--os", "linux,windows", "--cpu", "amd64,arm64",
+		"--format", "csv", "--summary-json-to-stderr")
+	if code != 0 {
+		t.Fatalf("exit status %d\n%s", code, stderr)
+	}
+	rows := strings.Count(stdout, "\n") - 1
+	if rows != 4 {
+		t.Fatalf("csv has %d rows, want 4:\n%s", rows, stdout)
+	}
+
+	// The summary is one line of JSON among the log lines
+	var summaries []TargetsSummary
+	for _, line := range strings.Split(stderr, "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var summary TargetsSummary
+		if err := json.Unmarshal([]byte(line), &summary); err != nil {
+			t.Fatalf("summary line %q: %v", line, err)
+		}
+		summaries = append(summaries, summary)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("%d summary lines on stderr:\n%s", len(summaries), stderr)
+	}
+	if s := summaries[0]; s.TotalCount != rows || s.HardcodedCount != rows || s.VerifiedCount != 0 || s.GeneratedAt == "" {
+		t.Errorf("summary %+v does not match the %d hardcoded targets", s, rows)
+	}
+	if strings.Contains(stdout, "total_count") {
+		t.Error("summary written to stdout")
+	}
+}
+
+func TestOnlySourceSubsets(t *testing.T) {
+	nim := writeStubNim(t, perOSNim)
+	run := func(source string) (names []string, code int) {
+		args := []string{"--nim-path", nim, "--skip-verify", "--os", "linux,haiku", "--cpu", "amd64,mips", "--format", "json"}
+		if source != "" {
+			args = append(args, "--only-source", source)
+		}
+		stdout, stderr, code := runMain(t, "", args...)
+		if code != 0 {
+			return nil, code
+		}
+		var result TargetsResult
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("%v\n%s", err, stderr)
+		}
+		for _, target := range result.Targets {
+			names = append(names, target.OS+"/"+target.CPU)
+		}
+		sort.Strings(names)
+		return names, 0
+	}
+
+	// haiku and mips are only built in
+	want := map[string][]string{
+		"detected":  {"linux/amd64"},
+		"mixed":     {"haiku/amd64", "linux/mips"},
+		"hardcoded": {"haiku/mips"},
+	}
+	var union []string
+	for source, names := range want {
+		got, code := run(source)
+		if code != 0 || !reflect.DeepEqual(got, names) {
+			t.Errorf("--only-source %s: %v (exit status %d), want %v", source, got, code, names)
+		}
+		union = append(union, got...)
+	}
+	sort.Strings(union)
+	if all, _ := run(""); !reflect.DeepEqual(union, all) {
+		t.Errorf("the subsets %v do not add up to all targets %v", union, all)
+	}
+	if _, code := run("external"); code != 1 {
+		t.Errorf("no external targets: exit status %d, want 1", code)
+	}
+}
+
+func TestKeepArtifactsLayout(t *testing.T) {
+	// The stub compiles by writing a C file into its --nimcache
+	nim := writeStubNim(t, `for arg; do
+	case "$arg" in
+	--nimcache:*) mkdir -p "${arg#--nimcache:}" && echo "int main;" >"${arg#--nimcache:}/@mstdin.nim.c" ;;
+	esac
+done
+case "$*" in
+--version) echo "Nim Compiler Version 2.0.2" ;;
+*--compileOnly*) exit 0 ;;
+*) exit 1 ;;
+esac
+`)
+	dir := t.TempDir()
+	_, stderr, code := runMain(t, "", "--nim-path", nim, "--keep-artifacts", dir,
+		"--os", "linux,windows", "--cpu", "amd64,arm64", "--format", "json")
+	if code != 0 {
+		t.Fatalf("exit status %d\n%s", code, stderr)
+	}
+
+	var got []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	want := []string{
+		"linux_amd64/c/@mstdin.nim.c",
+		"linux_arm64/c/@mstdin.nim.c",
+		"windows_amd64/c/@mstdin.nim.c",
+		"windows_arm64/c/@mstdin.nim.c",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("kept artifacts %q, want %q", got, want)
+	}
+
+	ts := NewTargetScanner()
+	ts.artifactsDir = dir
+	if sub := ts.artifactDir("linux", "amd64", []string{"--app:lib", "c"}); sub != filepath.Join(dir, "linux_amd64", "app_lib_c") {
+		t.Errorf("--app:lib artifacts go to %s", sub)
+	}
+}
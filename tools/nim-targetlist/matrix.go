@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// matrixEntry is one target listed in a --matrix-file.
+type matrixEntry struct {
+	OS      string `json:"os"`
+	CPU     string `json:"cpu"`
+	Backend string `json:"backend"`
+}
+
+// loadMatrixFile reads an explicit list of os/cpu/backend triples to verify
+// instead of the generated cross product. The backend is optional and
+// defaults as for generated targets.
+func (ts *TargetScanner) loadMatrixFile(path string) ([]TargetInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []matrixEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid matrix file %s: %v", path, err)
+	}
+
+	var targets []TargetInfo
+	for i, entry := range entries {
+		osName := strings.ToLower(strings.TrimSpace(entry.OS))
+		cpu := strings.ToLower(strings.TrimSpace(entry.CPU))
+		backend := strings.ToLower(strings.TrimSpace(entry.Backend))
+
+		if !ts.isValidTargetName(osName, "os") || !ts.isValidTargetName(cpu, "cpu") {
+			return nil, fmt.Errorf("entry %d: invalid target %q/%q", i, entry.OS, entry.CPU)
+		}
+		if backend != "" && !isKnownBackend(backend) {
+			return nil, fmt.Errorf("entry %d: unknown backend %q (known: %s)", i, entry.Backend, strings.Join(knownBackends, ", "))
+		}
+		if backend == "" {
+			backend = ts.backendFor(osName, cpu)
+		}
+
+		targets = append(targets, TargetInfo{
+			OS:         osName,
+			CPU:        cpu,
+			Backend:    backend,
+			Source:     "file",
+			Command:    ts.targetCommand(backend, osName, cpu),
+			CrossOnly:  crossOnlyCPUs[cpu],
+			Bits:       cpuLayouts[cpu].bits,
+			Endian:     cpuLayouts[cpu].endian,
+			Triple:     gnuTriples[osName+"/"+cpu],
+			Confidence: 1.0,
+			Usable:     true,
+		})
+	}
+	return targets, nil
+}
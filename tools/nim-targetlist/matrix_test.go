@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatrixFileVerifiesExactlyItsTargets(t *testing.T) {
+	ts, calls := recordingNimScanner(t, `case "$1" in --version) echo "Nim Compiler Version 2.0.2"; exit 0;; esac
+cat >/dev/null
+`)
+	// standalone/arm is not a common target, and linux/amd64 is listed
+	// under two backends
+	matrix := `[
+		{"os": "linux", "cpu": "amd64"},
+		{"os": "linux", "cpu": "amd64", "backend": "cpp"},
+		{"os": "standalone", "cpu": "arm", "backend": "c"}
+	]`
+	ts.matrixFile = filepath.Join(t.TempDir(), "matrix.json")
+	if err := os.WriteFile(ts.matrixFile, []byte(matrix), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	targets := ts.verifyTargets(ctx, ts.scanTargets(ctx))
+	if len(targets) != 3 {
+		t.Fatalf("got %d targets, want the 3 listed", len(targets))
+	}
+	for _, target := range targets {
+		if target.Source != "file" || target.VerifyStatus != verifyStatusVerified {
+			t.Errorf("%s/%s (%s): source %q, status %q", target.OS, target.CPU, target.Backend, target.Source, target.VerifyStatus)
+		}
+	}
+
+	var compiled []string
+	for _, call := range calls() {
+		if call == "--version" {
+			continue
+		}
+		args := strings.Fields(call)
+		// The backend is the last argument before the program on stdin
+		compiled = append(compiled, args[0]+" "+args[1]+" "+args[len(args)-2])
+	}
+	sort.Strings(compiled)
+	want := []string{
+		"--os:linux --cpu:amd64 c",
+		"--os:linux --cpu:amd64 cpp",
+		"--os:standalone --cpu:arm c",
+	}
+	if strings.Join(compiled, "\n") != strings.Join(want, "\n") {
+		t.Errorf("compiled %q, want %q", compiled, want)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// NameMap renames nim's OS and CPU names to the names a consumer uses, e.g.
+// {"os": {"macosx": "darwin"}, "cpu": {"amd64": "x86_64"}}.
+type NameMap struct {
+	OS  map[string]string `json:"os"`
+	CPU map[string]string `json:"cpu"`
+}
+
+func loadNameMap(path string) (*NameMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m NameMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid name map %s: %v", path, err)
+	}
+	return &m, nil
+}
+
+// apply renames the OS and CPU of each target. It runs only at output time;
+// commands keep nim's names since they are meant to be run with nim.
+func (m *NameMap) apply(targets []TargetInfo) {
+	for i := range targets {
+		m.rename(&targets[i])
+	}
+}
+
+// rename renames the OS and CPU of a single target.
+func (m *NameMap) rename(target *TargetInfo) {
+	target.OS, target.CPU = m.names(target.OS, target.CPU)
+}
+
+// renamed returns a renamed copy of targets, for reports taken while the
+// targets themselves still need nim's names.
+func (m *NameMap) renamed(targets []TargetInfo) []TargetInfo {
+	renamed := append([]TargetInfo(nil), targets...)
+	m.apply(renamed)
+	return renamed
+}
+
+// applyComparison renames the divergent targets of a backend comparison.
+func (m *NameMap) applyComparison(comparison *BackendComparison) {
+	for i := range comparison.Divergent {
+		d := &comparison.Divergent[i]
+		d.OS, d.CPU = m.names(d.OS, d.CPU)
+	}
+}
+
+// names returns the output names for a nim OS and CPU name.
+func (m *NameMap) names(osName, cpu string) (string, string) {
+	if name, ok := m.OS[osName]; ok {
+		osName = name
+	}
+	if name, ok := m.CPU[cpu]; ok {
+		cpu = name
+	}
+	return osName, cpu
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testNameMap() *NameMap {
+	return &NameMap{
+		OS:  map[string]string{"macosx": "darwin"},
+		CPU: map[string]string{"amd64": "x86_64"},
+	}
+}
+
+func TestNameMapPartialOrderReport(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "macosx", CPU: "amd64", VerifyStatus: verifyStatusVerified},
+		{OS: "linux", CPU: "amd64", VerifyStatus: verifyStatusFailed},
+		{OS: "macosx", CPU: "arm64", VerifyStatus: verifyStatusSkipped},
+	}
+	report := newPartialOrderReport(testNameMap().renamed(targets))
+	want := partialOrderReport{
+		Verified:     []string{"darwin/x86_64"},
+		Failed:       []string{"linux/x86_64"},
+		NotAttempted: []string{"darwin/arm64"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	// The targets keep nim's names for everything after the report
+	if targets[0].OS != "macosx" || targets[0].CPU != "amd64" {
+		t.Errorf("renamed modified the targets: %+v", targets[0])
+	}
+}
+
+func TestNameMapOSScores(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "macosx", CPU: "amd64", Verified: true},
+		{OS: "macosx", CPU: "arm64"},
+		{OS: "linux", CPU: "amd64", Verified: true},
+	}
+	testNameMap().apply(targets)
+	want := []osScore{{OS: "darwin", Verified: 1, Total: 2}, {OS: "linux", Verified: 1, Total: 1}}
+	if got := osScores(targets); !reflect.DeepEqual(got, want) {
+		t.Errorf("osScores() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNameMapBackendComparison(t *testing.T) {
+	comparison := BackendComparison{
+		Backends: []string{"c", "cpp"},
+		Divergent: []BackendDivergence{
+			{OS: "macosx", CPU: "amd64", Verified: map[string]bool{"c": true, "cpp": false}},
+		},
+	}
+	testNameMap().applyComparison(&comparison)
+	if d := comparison.Divergent[0]; d.OS != "darwin" || d.CPU != "x86_64" {
+		t.Errorf("divergent target not renamed: %+v", d)
+	}
+}
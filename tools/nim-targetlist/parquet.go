@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parquet physical types, and the Thrift compact protocol field types
+// used by the footer and page headers.
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+const parquetMagic = "PAR1"
+
+// parquetColumn is one column of the Parquet output. Every column is
+// required, so pages hold no definition or repetition levels.
+type parquetColumn struct {
+	name  string
+	typ   int32
+	value func(TargetInfo) any
+}
+
+// parquetColumns is the schema of --format parquet: the scalar fields of
+// TargetInfo, typed. Aliases and AppModes, which have no flat Parquet
+// type, are flattened into comma-separated strings.
+var parquetColumns = []parquetColumn{
+	{"os", parquetByteArray, func(t TargetInfo) any { return t.OS }},
+	{"cpu", parquetByteArray, func(t TargetInfo) any { return t.CPU }},
+	{"verified", parquetBoolean, func(t TargetInfo) any { return t.Verified }},
+	{"verify_status", parquetByteArray, func(t TargetInfo) any { return t.VerifyStatus }},
+	{"source", parquetByteArray, func(t TargetInfo) any { return t.Source }},
+	{"command", parquetByteArray, func(t TargetInfo) any { return t.Command }},
+	{"cross_only", parquetBoolean, func(t TargetInfo) any { return t.CrossOnly }},
+	{"confidence", parquetDouble, func(t TargetInfo) any { return t.Confidence }},
+	{"backend", parquetByteArray, func(t TargetInfo) any { return t.Backend }},
+	{"skip_reason", parquetByteArray, func(t TargetInfo) any { return t.SkipReason }},
+	{"fail_reason", parquetByteArray, func(t TargetInfo) any { return t.FailReason }},
+	{"binary_size_bytes", parquetInt64, func(t TargetInfo) any { return t.BinarySizeBytes }},
+	{"runtime_warning", parquetByteArray, func(t TargetInfo) any { return t.RuntimeWarning }},
+	{"app_modes", parquetByteArray, func(t TargetInfo) any { return joinAppModes(t.AppModes) }},
+	{"deprecated", parquetBoolean, func(t TargetInfo) any { return t.Deprecated }},
+	{"verify_output", parquetByteArray, func(t TargetInfo) any { return t.VerifyOutput }},
+	{"usable", parquetBoolean, func(t TargetInfo) any { return t.Usable }},
+	{"aliases", parquetByteArray, func(t TargetInfo) any { return strings.Join(t.Aliases, ",") }},
+	{"bits", parquetInt32, func(t TargetInfo) any { return int32(t.Bits) }},
+	{"endian", parquetByteArray, func(t TargetInfo) any { return t.Endian }},
+	{"triple", parquetByteArray, func(t TargetInfo) any { return t.Triple }},
+}
+
+// joinAppModes renders AppModes as sorted mode=bool pairs, e.g.
+// "gui=false,lib=true".
+func joinAppModes(modes map[string]bool) string {
+	pairs := make([]string, 0, len(modes))
+	for mode, ok := range modes {
+		pairs = append(pairs, mode+"="+strconv.FormatBool(ok))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// thriftWriter encodes a Thrift struct in the compact protocol, which
+// Parquet uses for its footer and page headers. Like protoBuffer it is
+// written by hand to keep the tool free of dependencies, and covers only
+// the field types parquet.go needs.
+type thriftWriter struct {
+	buf       []byte
+	lastField int
+}
+
+func (w *thriftWriter) field(id, typ int) {
+	if delta := id - w.lastField; delta > 0 && delta <= 15 {
+		w.buf = append(w.buf, byte(delta<<4|typ))
+	} else {
+		w.buf = append(w.buf, byte(typ))
+		w.buf = binary.AppendVarint(w.buf, int64(id))
+	}
+	w.lastField = id
+}
+
+func (w *thriftWriter) i32(id int, v int32) {
+	w.field(id, thriftI32)
+	w.buf = binary.AppendVarint(w.buf, int64(v))
+}
+
+func (w *thriftWriter) i64(id int, v int64) {
+	w.field(id, thriftI64)
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *thriftWriter) binary(id int, v string) {
+	w.field(id, thriftBinary)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
+
+func (w *thriftWriter) listHeader(id, size, elemType int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf = append(w.buf, byte(size<<4|elemType))
+	} else {
+		w.buf = append(w.buf, byte(0xf0|elemType))
+		w.buf = binary.AppendUvarint(w.buf, uint64(size))
+	}
+}
+
+func (w *thriftWriter) i32List(id int, values ...int32) {
+	w.listHeader(id, len(values), thriftI32)
+	for _, v := range values {
+		w.buf = binary.AppendVarint(w.buf, int64(v))
+	}
+}
+
+func (w *thriftWriter) binaryList(id int, values ...string) {
+	w.listHeader(id, len(values), thriftBinary)
+	for _, v := range values {
+		w.buf = binary.AppendUvarint(w.buf, uint64(len(v)))
+		w.buf = append(w.buf, v...)
+	}
+}
+
+func (w *thriftWriter) structField(id int, s thriftWriter) {
+	w.field(id, thriftStruct)
+	w.buf = append(w.buf, s.end()...)
+}
+
+func (w *thriftWriter) structList(id int, structs []thriftWriter) {
+	w.listHeader(id, len(structs), thriftStruct)
+	for _, s := range structs {
+		w.buf = append(w.buf, s.end()...)
+	}
+}
+
+// end returns the encoded struct, terminated by its stop field.
+func (w *thriftWriter) end() []byte {
+	return append(w.buf, 0)
+}
+
+// encodeParquetColumn encodes every target's value of column as PLAIN
+// data: booleans bit-packed, numbers little-endian, and strings
+// prefixed with their 4-byte length.
+func encodeParquetColumn(column parquetColumn, targets []TargetInfo) []byte {
+	var data []byte
+	if column.typ == parquetBoolean {
+		data = make([]byte, (len(targets)+7)/8)
+	}
+	for i, target := range targets {
+		switch v := column.value(target).(type) {
+		case bool:
+			if v {
+				data[i/8] |= 1 << (i % 8)
+			}
+		case int32:
+			data = binary.LittleEndian.AppendUint32(data, uint32(v))
+		case int64:
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		case float64:
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		case string:
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(v)))
+			data = append(data, v...)
+		}
+	}
+	return data
+}
+
+// outputParquet writes targets as a Parquet file with one row group and
+// one uncompressed, PLAIN-encoded data page per column.
+func outputParquet(w io.Writer, targets []TargetInfo, nimVersion string) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	numRows := int64(len(targets))
+	chunks := make([]thriftWriter, len(parquetColumns))
+	var totalSize int64
+	for i, column := range parquetColumns {
+		data := encodeParquetColumn(column, targets)
+		var pageHeader, dataPageHeader thriftWriter
+		dataPageHeader.i32(1, int32(len(targets))) // num_values
+		dataPageHeader.i32(2, 0)                   // encoding: PLAIN
+		dataPageHeader.i32(3, 3)                   // definition_level_encoding: RLE
+		dataPageHeader.i32(4, 3)                   // repetition_level_encoding: RLE
+		pageHeader.i32(1, 0)                       // type: DATA_PAGE
+		pageHeader.i32(2, int32(len(data)))        // uncompressed_page_size
+		pageHeader.i32(3, int32(len(data)))        // compressed_page_size
+		pageHeader.structField(5, dataPageHeader)
+		header := pageHeader.end()
+
+		offset := int64(file.Len())
+		file.Write(header)
+		file.Write(data)
+		size := int64(len(header) + len(data))
+		totalSize += size
+
+		var metaData thriftWriter
+		metaData.i32(1, column.typ)
+		metaData.i32List(2, 0) // encodings: PLAIN
+		metaData.binaryList(3, column.name)
+		metaData.i32(4, 0) // codec: UNCOMPRESSED
+		metaData.i64(5, numRows)
+		metaData.i64(6, size)
+		metaData.i64(7, size)
+		metaData.i64(9, offset)
+		chunks[i].i64(2, offset) // file_offset
+		chunks[i].structField(3, metaData)
+	}
+
+	schema := make([]thriftWriter, 1, len(parquetColumns)+1)
+	schema[0].binary(4, "schema")
+	schema[0].i32(5, int32(len(parquetColumns)))
+	for _, column := range parquetColumns {
+		var element thriftWriter
+		element.i32(1, column.typ)
+		element.i32(3, 0) // repetition_type: REQUIRED
+		element.binary(4, column.name)
+		if column.typ == parquetByteArray {
+			element.i32(6, 0) // converted_type: UTF8
+		}
+		schema = append(schema, element)
+	}
+
+	var rowGroup thriftWriter
+	rowGroup.structList(1, chunks)
+	rowGroup.i64(2, totalSize)
+	rowGroup.i64(3, numRows)
+
+	var footer thriftWriter
+	footer.i32(1, 1) // version
+	footer.structList(2, schema)
+	footer.i64(3, numRows)
+	footer.structList(4, []thriftWriter{rowGroup})
+	createdBy := "nim-targetlist"
+	if nimVersion != "" {
+		createdBy += " (nim " + nimVersion + ")"
+	}
+	footer.binary(6, createdBy)
+	metadata := footer.end()
+
+	file.Write(metadata)
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata))))
+	file.WriteString(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// thriftReader decodes the compact protocol structs written by
+// thriftWriter into maps keyed by field id. Lists become []any, binaries
+// strings and integers int64.
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.t.Fatalf("thrift data ends at %d", r.pos)
+	}
+	r.pos++
+	return r.data[r.pos-1]
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("bad varint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("bad uvarint at %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case thriftList:
+		header := r.byte()
+		size, elemType := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]any, size)
+		for i := range list {
+			list[i] = r.value(elemType)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.t.Fatalf("unexpected thrift type %d at %d", typ, r.pos)
+	return nil
+}
+
+func (r *thriftReader) readStruct() map[int]any {
+	fields := make(map[int]any)
+	last := 0
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int(header>>4)
+		if header>>4 == 0 {
+			id = int(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+// readParquet decodes a file written by outputParquet into its column
+// names and rows, checking the layout on the way.
+func readParquet(t *testing.T, data []byte) (names []string, rows [][]any) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&thriftReader{t: t, data: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+
+	numRows := int(footer[3].(int64))
+	schema := footer[2].([]any)
+	if root := schema[0].(map[int]any); root[5] != int64(len(schema)-1) {
+		t.Fatalf("root schema element %v", root)
+	}
+	rowGroups := footer[4].([]any)
+	if len(rowGroups) != 1 {
+		t.Fatalf("%d row groups", len(rowGroups))
+	}
+	chunks := rowGroups[0].(map[int]any)[1].([]any)
+	if len(chunks) != len(schema)-1 {
+		t.Fatalf("%d column chunks for %d columns", len(chunks), len(schema)-1)
+	}
+
+	rows = make([][]any, numRows)
+	for col, chunk := range chunks {
+		element := schema[col+1].(map[int]any)
+		names = append(names, element[4].(string))
+		meta := chunk.(map[int]any)[3].(map[int]any)
+		if path := meta[3].([]any); len(path) != 1 || path[0] != element[4] {
+			t.Fatalf("column %d has path %v", col, path)
+		}
+		if meta[1] != element[1] || meta[5] != int64(numRows) {
+			t.Fatalf("column %s metadata %v", names[col], meta)
+		}
+
+		reader := &thriftReader{t: t, data: data, pos: int(meta[9].(int64))}
+		header := reader.readStruct()
+		pageSize := int(header[3].(int64))
+		if header[2] != header[3] || header[5].(map[int]any)[1] != int64(numRows) {
+			t.Fatalf("column %s page header %v", names[col], header)
+		}
+		page := data[reader.pos : reader.pos+pageSize]
+		for i := range rows {
+			switch element[1] {
+			case int64(parquetBoolean):
+				rows[i] = append(rows[i], page[i/8]&(1<<(i%8)) != 0)
+			case int64(parquetInt32):
+				rows[i] = append(rows[i], int32(binary.LittleEndian.Uint32(page)))
+				page = page[4:]
+			case int64(parquetInt64):
+				rows[i] = append(rows[i], int64(binary.LittleEndian.Uint64(page)))
+				page = page[8:]
+			case int64(parquetDouble):
+				rows[i] = append(rows[i], math.Float64frombits(binary.LittleEndian.Uint64(page)))
+				page = page[8:]
+			case int64(parquetByteArray):
+				n := binary.LittleEndian.Uint32(page)
+				rows[i] = append(rows[i], string(page[4:4+n]))
+				page = page[4+n:]
+			default:
+				t.Fatalf("column %s has type %v", names[col], element[1])
+			}
+		}
+		if element[1] != int64(parquetBoolean) && len(page) != 0 {
+			t.Fatalf("column %s has %d bytes left over", names[col], len(page))
+		}
+	}
+	return names, rows
+}
+
+func TestOutputParquet(t *testing.T) {
+	targets, _ := sampleTargets()
+	targets[0].Confidence = 0.75
+	targets[0].BinarySizeBytes = 12345
+	targets[0].Triple = "x86_64-linux-gnu"
+	targets[0].Aliases = []string{"gnu"}
+	targets[0].AppModes = map[string]bool{"lib": true, "gui": false}
+	var buf bytes.Buffer
+	if err := outputParquet(&buf, targets, "2.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	names, rows := readParquet(t, buf.Bytes())
+	if len(rows) != len(targets) {
+		t.Fatalf("%d rows, want %d", len(rows), len(targets))
+	}
+	for i, column := range parquetColumns {
+		if names[i] != column.name {
+			t.Errorf("column %d is %q, want %q", i, names[i], column.name)
+		}
+	}
+
+	// Compare a sampled row with the target it came from
+	row := make(map[string]any)
+	for i, name := range names {
+		row[name] = rows[0][i]
+	}
+	target := targets[0]
+	want := map[string]any{
+		"os":                target.OS,
+		"cpu":               target.CPU,
+		"verified":          target.Verified,
+		"verify_status":     target.VerifyStatus,
+		"source":            target.Source,
+		"command":           target.Command,
+		"cross_only":        target.CrossOnly,
+		"confidence":        target.Confidence,
+		"backend":           target.Backend,
+		"skip_reason":       target.SkipReason,
+		"fail_reason":       target.FailReason,
+		"binary_size_bytes": target.BinarySizeBytes,
+		"runtime_warning":   target.RuntimeWarning,
+		"app_modes":         "gui=false,lib=true",
+		"deprecated":        target.Deprecated,
+		"verify_output":     target.VerifyOutput,
+		"usable":            target.Usable,
+		"aliases":           "gnu",
+		"bits":              int32(target.Bits),
+		"endian":            target.Endian,
+		"triple":            target.Triple,
+	}
+	if !reflect.DeepEqual(row, want) {
+		t.Errorf("row 0 = %v\nwant %v", row, want)
+	}
+}
+
+func TestParquetFormatWritesOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.parquet")
+	_, stderr, code := runMain(t, "", "--hardcoded-only", "--os", "linux,windows", "--cpu", "amd64,arm64",
+		"--format", "parquet", "--output", path)
+	if code != 0 {
+		t.Fatalf("exit status %d\n%s", code, stderr)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, rows := readParquet(t, data); len(rows) != 4 {
+		t.Errorf("%d rows, want 4", len(rows))
+	}
+}
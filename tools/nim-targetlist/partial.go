@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// partialOrderReport partitions the targets of an interrupted run by how
+// far verification got with each, as "os/cpu" names in output order.
+type partialOrderReport struct {
+	Verified     []string `json:"verified"`
+	Failed       []string `json:"failed"`
+	NotAttempted []string `json:"not_attempted"`
+}
+
+// newPartialOrderReport sorts targets into the report. Anything without a
+// finished compile, whether cancelled, skipped or never eligible, counts
+// as not attempted.
+func newPartialOrderReport(targets []TargetInfo) partialOrderReport {
+	report := partialOrderReport{Verified: []string{}, Failed: []string{}, NotAttempted: []string{}}
+	for _, target := range targets {
+		name := target.OS + "/" + target.CPU
+		switch target.VerifyStatus {
+		case verifyStatusVerified:
+			report.Verified = append(report.Verified, name)
+		case verifyStatusFailed:
+			report.Failed = append(report.Failed, name)
+		default:
+			report.NotAttempted = append(report.NotAttempted, name)
+		}
+	}
+	return report
+}
+
+// outputPartialOrderReport writes the report as a JSON object of the three
+// lists, as CSV with one target per row, or as a table of the lists.
+func outputPartialOrderReport(w io.Writer, report partialOrderReport, format string) error {
+	partitions := []struct {
+		name    string
+		targets []string
+	}{
+		{"verified", report.Verified},
+		{"failed", report.Failed},
+		{"not_attempted", report.NotAttempted},
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"os", "cpu", "state"}); err != nil {
+			return err
+		}
+		for _, p := range partitions {
+			for _, target := range p.targets {
+				osName, cpu, _ := strings.Cut(target, "/")
+				if err := writer.Write([]string{osName, cpu, p.name}); err != nil {
+					return err
+				}
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "State\tCount\tTargets")
+		fmt.Fprintln(tw, "─────\t─────\t───────")
+		for _, p := range partitions {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", p.name, len(p.targets), strings.Join(p.targets, " "))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("--verify-partial-order-report does not support format %q", format)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInterruptedRunReportsPartialOrder(t *testing.T) {
+	dir := t.TempDir()
+	started := filepath.Join(dir, "started")
+	// linux verifies, freebsd fails and windows hangs until interrupted,
+	// leaving macosx unattempted
+	nim := writeStubNim(t, fmt.Sprintf(`case "$1" in --version) echo "Nim Compiler Version 2.0.2"; exit 0;; esac
+cat >/dev/null
+case "$*" in
+*--os:freebsd*) echo "Error: unsupported"; exit 1;;
+*--os:windows*) touch '%s'; exec sleep 10;;
+esac
+`, started))
+	matrix := filepath.Join(dir, "matrix.json")
+	if err := os.WriteFile(matrix, []byte(`[
+		{"os": "linux", "cpu": "amd64"},
+		{"os": "freebsd", "cpu": "amd64"},
+		{"os": "windows", "cpu": "amd64"},
+		{"os": "macosx", "cpu": "amd64"}
+	]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runMain(t, started, "--nim-path", nim, "--matrix-file", matrix,
+		"--workers", "1", "--verify-partial-order-report", "--format", "json")
+	if code != exitInterrupted {
+		t.Errorf("exit status %d, want %d\n%s", code, exitInterrupted, stderr)
+	}
+
+	var report partialOrderReport
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("output is not a partial-order report: %v\n%s", err, stdout)
+	}
+	want := partialOrderReport{
+		Verified:     []string{"linux/amd64"},
+		Failed:       []string{"freebsd/amd64"},
+		NotAttempted: []string{"windows/amd64", "macosx/amd64"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// nimDump is the part of `nim dump --dump.format:json` output used to find
+// the compiler sources.
+type nimDump struct {
+	PrefixDir string `json:"prefixdir"`
+	LibPath   string `json:"libpath"`
+}
+
+var platformNamePattern = regexp.MustCompile(`name:\s*"([^"]+)"`)
+
+// platformSourcePaths returns where compiler/platform.nim may live relative
+// to the directories reported by nim dump: next to lib/ in source and
+// choosenim installs, or under the prefix in distribution packages.
+func platformSourcePaths(dump nimDump) []string {
+	var paths []string
+	if dump.LibPath != "" {
+		paths = append(paths,
+			filepath.Join(dump.LibPath, "..", "compiler", "platform.nim"),
+			filepath.Join(dump.LibPath, "compiler", "platform.nim"))
+	}
+	if dump.PrefixDir != "" {
+		paths = append(paths,
+			filepath.Join(dump.PrefixDir, "compiler", "platform.nim"),
+			filepath.Join(dump.PrefixDir, "lib", "nim", "compiler", "platform.nim"))
+	}
+	return paths
+}
+
+// readPlatformTables reads the OS and CPU tables nim itself validates
+// --os and --cpu against from compiler/platform.nim, located through
+// `nim dump`. Unlike parsing help output this is exact, but it only works
+// where the compiler sources are installed alongside the standard library.
+func (ts *TargetScanner) readPlatformTables(ctx context.Context) (oses, cpus []string, err error) {
+	dumpCtx, cancel := context.WithTimeout(ctx, ts.timeout)
+	defer cancel()
+
+	args := []string{"dump", "--dump.format:json", "dummy"}
+	output, err := exec.CommandContext(dumpCtx, ts.nimBinary, args...).CombinedOutput()
+	ts.noteExecError(ctx, args, err)
+	ts.dumpRaw("platform", args, output)
+
+	// Hints may be printed around the JSON document
+	start, end := strings.IndexByte(string(output), '{'), strings.LastIndexByte(string(output), '}')
+	if start < 0 || end < start {
+		return nil, nil, fmt.Errorf("no JSON in nim dump output")
+	}
+	var dump nimDump
+	if err := json.Unmarshal(output[start:end+1], &dump); err != nil {
+		return nil, nil, fmt.Errorf("invalid nim dump output: %v", err)
+	}
+
+	for _, path := range platformSourcePaths(dump) {
+		source, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if ts.debugMode {
+			log.Printf("Reading platform tables from %s", path)
+		}
+		return parsePlatformTables(string(source))
+	}
+	return nil, nil, fmt.Errorf("compiler/platform.nim not found next to %s", dump.LibPath)
+}
+
+// fullConfidence scores every name 1.0, for names taken from nim's own
+// tables rather than scraped from help text.
+func fullConfidence(names []string) map[string]float64 {
+	confidence := make(map[string]float64, len(names))
+	for _, name := range names {
+		confidence[name] = 1.0
+	}
+	return confidence
+}
+
+// parsePlatformTables extracts the names from the OS and CPU array
+// constants of platform.nim. nim matches --os and --cpu case-insensitively,
+// so the names are lowercased to their command-line spelling.
+func parsePlatformTables(source string) (oses, cpus []string, err error) {
+	if oses, err = platformTableNames(source, "OS"); err != nil {
+		return nil, nil, err
+	}
+	if cpus, err = platformTableNames(source, "CPU"); err != nil {
+		return nil, nil, err
+	}
+	return oses, cpus, nil
+}
+
+// platformTableNames returns the name fields of the exported array
+// constant called table, e.g. "OS".
+func platformTableNames(source, table string) ([]string, error) {
+	decl := regexp.MustCompile(`(?m)^\s*` + table + `\*\s*:\s*array\b[^=]*=\s*\[`).FindStringIndex(source)
+	if decl == nil {
+		return nil, fmt.Errorf("%s table not found", table)
+	}
+	body := source[decl[1]:]
+
+	// The entries are tuples, so the first closing bracket ends the table
+	if end := strings.IndexByte(body, ']'); end >= 0 {
+		body = body[:end]
+	}
+
+	var names []string
+	for _, match := range platformNamePattern.FindAllStringSubmatch(body, -1) {
+		names = append(names, strings.ToLower(match[1]))
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s table is empty", table)
+	}
+	return names, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	prettyDefaultWidth = 80
+	prettyMinWidth     = 40
+	prettyMaxWidth     = 120
+	prettyTopTargets   = 10
+)
+
+// terminalWidth returns the width to render w at, clamped to a readable
+// range. It is the size of the terminal when w is one, otherwise $COLUMNS
+// (set by most interactive shells).
+func terminalWidth(w io.Writer) int {
+	width := prettyDefaultWidth
+	if f, ok := w.(*os.File); ok && ttyColumns(f) > 0 {
+		width = ttyColumns(f)
+	} else if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		width = n
+	}
+	if width < prettyMinWidth {
+		width = prettyMinWidth
+	}
+	if width > prettyMaxWidth {
+		width = prettyMaxWidth
+	}
+	return width
+}
+
+type prettySection struct {
+	title string
+	lines []string
+}
+
+// outputPretty renders a boxed, human-oriented summary: the nim install,
+// target counts and the most popular verified targets.
+func outputPretty(w io.Writer, targets []TargetInfo, scanner *TargetScanner, width int) error {
+	verifiedCount, detectedCount, hardcodedCount := countTargets(targets)
+
+	nimVersion := scanner.nimVersion
+	if !scanner.nimAvailable {
+		nimVersion = "not available"
+	} else if nimVersion == "" {
+		nimVersion = "unknown"
+	}
+
+	var verified []TargetInfo
+	for _, target := range targets {
+		if target.Verified {
+			verified = append(verified, target)
+		}
+	}
+	rankTargets(verified)
+
+	var top []string
+	for i, target := range verified {
+		if i == prettyTopTargets {
+			top = append(top, fmt.Sprintf("… and %d more", len(verified)-prettyTopTargets))
+			break
+		}
+		top = append(top, fmt.Sprintf("✓ %s/%s", target.OS, target.CPU))
+	}
+	if len(top) == 0 {
+		top = []string{"(none verified)"}
+	}
+
+	sections := []prettySection{
+		{title: "Nim", lines: []string{
+			"Version:  " + nimVersion,
+			"Backends: " + orNone(strings.Join(scanner.backends, ", ")),
+		}},
+		{title: "Summary", lines: []string{
+			fmt.Sprintf("Total targets:   %d", len(targets)),
+			fmt.Sprintf("Verified:        %d", verifiedCount),
+			fmt.Sprintf("Detected:        %d", detectedCount),
+			fmt.Sprintf("Hardcoded:       %d", hardcodedCount),
+		}},
+		{title: "Top verified targets", lines: top},
+	}
+
+	_, err := io.WriteString(w, renderBox("Nim Targets", sections, width))
+	return err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// rankTargets orders targets by popularity of their OS, then their CPU.
+func rankTargets(targets []TargetInfo) {
+	rank := func(ranking []string, name string) int {
+		for i, r := range ranking {
+			if r == name {
+				return i
+			}
+		}
+		return len(ranking)
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		oi, oj := rank(popularOSes, targets[i].OS), rank(popularOSes, targets[j].OS)
+		if oi != oj {
+			return oi < oj
+		}
+		return rank(popularCPUs, targets[i].CPU) < rank(popularCPUs, targets[j].CPU)
+	})
+}
+
+// renderBox draws the sections inside a Unicode box exactly width columns
+// wide, truncating lines that do not fit. The title rule tops the first
+// section, so only the later ones get a rule of their own.
+func renderBox(title string, sections []prettySection, width int) string {
+	inner := width - 4 // "│ " + content + " │"
+
+	rule := func(left, label, right string) string {
+		if label == "" {
+			return left + strings.Repeat("─", width-2) + right + "\n"
+		}
+		label = truncateRunes(label, width-6)
+		fill := width - 5 - utf8.RuneCountInString(label)
+		return left + "─ " + label + " " + strings.Repeat("─", fill) + right + "\n"
+	}
+	row := func(text string) string {
+		text = truncateRunes(text, inner)
+		return "│ " + text + strings.Repeat(" ", inner-utf8.RuneCountInString(text)) + " │\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(rule("┌", title, "┐"))
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString(rule("├", section.title, "┤"))
+		}
+		for _, line := range section.lines {
+			b.WriteString(row(line))
+		}
+	}
+	b.WriteString(rule("└", "", "┘"))
+	return b.String()
+}
+
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
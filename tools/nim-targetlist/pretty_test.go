@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderBox(t *testing.T) {
+	sections := []prettySection{
+		{title: "Nim", lines: []string{"Version:  2.0.2"}},
+		{title: "Summary", lines: []string{"Total targets:   3", strings.Repeat("x", 100)}},
+		{lines: []string{"untitled"}},
+	}
+	lines := strings.Split(strings.TrimSuffix(renderBox("Nim Targets", sections, 40), "\n"), "\n")
+
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n != 40 {
+			t.Errorf("%q is %d columns wide, want 40", line, n)
+		}
+	}
+	if !strings.HasPrefix(lines[0], "┌─ Nim Targets ") || !strings.HasPrefix(lines[1], "│ Version:") {
+		t.Errorf("the first section does not start right under the title:\n%s", strings.Join(lines, "\n"))
+	}
+	var rules []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "├") {
+			rules = append(rules, line)
+		}
+	}
+	if len(rules) != 2 || !strings.HasPrefix(rules[0], "├─ Summary ") || strings.Trim(rules[1], "├─┤") != "" {
+		t.Errorf("section rules = %q, want one for Summary and a plain one", rules)
+	}
+	if !strings.HasSuffix(lines[4], "… │") {
+		t.Errorf("long line not truncated: %q", lines[4])
+	}
+}
+
+func TestTerminalWidth(t *testing.T) {
+	tests := []struct {
+		columns string
+		want    int
+	}{
+		{"", prettyDefaultWidth},
+		{"junk", prettyDefaultWidth},
+		{"100", 100},
+		{"10", prettyMinWidth},
+		{"500", prettyMaxWidth},
+	}
+	for _, tt := range tests {
+		t.Setenv("COLUMNS", tt.columns)
+		if got := terminalWidth(&bytes.Buffer{}); got != tt.want {
+			t.Errorf("COLUMNS=%q: width %d, want %d", tt.columns, got, tt.want)
+		}
+	}
+
+	// A regular file is not a terminal, so $COLUMNS still decides
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if n := ttyColumns(f); n != 0 {
+		t.Errorf("ttyColumns() = %d for a regular file", n)
+	}
+	t.Setenv("COLUMNS", "100")
+	if got := terminalWidth(f); got != 100 {
+		t.Errorf("width %d for a regular file, want $COLUMNS", got)
+	}
+}
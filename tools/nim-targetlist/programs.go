@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadTestPrograms reads every <name>.nim file in dir, keyed by name. The
+// names are OS or CPU names, so they are lowercased to match.
+func loadTestPrograms(dir string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.nim"))
+	if err != nil {
+		return nil, err
+	}
+
+	programs := make(map[string]string, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".nim"))
+		programs[name] = string(data)
+	}
+	return programs, nil
+}
+
+// testProgram returns the program to verify a target with: the CPU's own
+// test file if there is one, then the OS's, then --verify-source, then the
+// built-in program.
+func (ts *TargetScanner) testProgram(osName, cpu string) string {
+	if program, ok := ts.cpuPrograms[cpu]; ok {
+		return program
+	}
+	if program, ok := ts.osPrograms[osName]; ok {
+		return program
+	}
+	if ts.defaultProgram != "" {
+		return ts.defaultProgram
+	}
+	return verifyProgram
+}
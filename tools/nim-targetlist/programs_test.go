@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePrograms(t *testing.T, programs map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, program := range programs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(program), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTestProgramPrecedence(t *testing.T) {
+	var err error
+	// The stub echoes the program it was given
+	ts := stubNimScanner(t, "cat\n")
+	ts.verifyAll = true
+	ts.RetainVerifyOutput = true
+	ts.defaultProgram = "echo \"source\"\n"
+	ts.osPrograms, err = loadTestPrograms(writePrograms(t, map[string]string{
+		"Linux.nim":   "echo \"linux\"\n",
+		"windows.nim": "echo \"windows\"\n",
+		"notes.txt":   "not a program",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.cpuPrograms, err = loadTestPrograms(writePrograms(t, map[string]string{
+		"avr.nim": "echo \"avr\"\n",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.osPrograms) != 2 {
+		t.Errorf("loaded %d OS programs, want the 2 .nim files", len(ts.osPrograms))
+	}
+
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: "linux", CPU: "amd64"},
+		{OS: "linux", CPU: "avr"},
+		{OS: "freebsd", CPU: "amd64"},
+	})
+	want := []string{"echo \"linux\"\n", "echo \"avr\"\n", "echo \"source\"\n"}
+	for i, target := range targets {
+		if target.VerifyOutput != want[i] {
+			t.Errorf("%s/%s verified with %q, want %q", target.OS, target.CPU, target.VerifyOutput, want[i])
+		}
+	}
+
+	ts.defaultProgram = ""
+	if got := ts.testProgram("freebsd", "amd64"); got != verifyProgram {
+		t.Errorf("testProgram() = %q, want the built-in program", got)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+)
+
+// Protobuf wire types used by targets.proto.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// protoBuffer encodes the proto3 wire format for the messages in
+// targets.proto. The encoding is written by hand to keep the tool free of
+// dependencies, which generated types would need; field numbers must be
+// kept in sync with the schema, which protobuf_test.go checks by decoding
+// the output against targets.proto. As in proto3, fields holding their
+// zero value are omitted.
+type protoBuffer []byte
+
+func (b *protoBuffer) tag(field, wireType int) {
+	*b = binary.AppendUvarint(*b, uint64(field)<<3|uint64(wireType))
+}
+
+func (b *protoBuffer) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, protoVarint)
+	*b = binary.AppendUvarint(*b, v)
+}
+
+func (b *protoBuffer) int64Field(field int, v int64) {
+	b.varint(field, uint64(v))
+}
+
+func (b *protoBuffer) boolField(field int, v bool) {
+	if v {
+		b.varint(field, 1)
+	}
+}
+
+func (b *protoBuffer) doubleField(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, protoFixed64)
+	*b = binary.LittleEndian.AppendUint64(*b, math.Float64bits(v))
+}
+
+func (b *protoBuffer) stringField(field int, v string) {
+	if v == "" {
+		return
+	}
+	b.tag(field, protoBytes)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+// message embeds an encoded submessage. Unlike scalars it is written even
+// when empty, since repeated and map entries must keep their position.
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.tag(field, protoBytes)
+	*b = binary.AppendUvarint(*b, uint64(len(m)))
+	*b = append(*b, m...)
+}
+
+func encodeTargetInfo(target TargetInfo) protoBuffer {
+	var b protoBuffer
+	b.stringField(1, target.OS)
+	b.stringField(2, target.CPU)
+	b.boolField(3, target.Verified)
+	b.stringField(4, target.Source)
+	b.stringField(5, target.Command)
+	b.boolField(6, target.CrossOnly)
+	b.doubleField(7, target.Confidence)
+	b.stringField(8, target.Backend)
+	b.stringField(9, target.SkipReason)
+	b.int64Field(10, target.BinarySizeBytes)
+	b.stringField(11, target.RuntimeWarning)
+
+	// Map entries are sorted so the output is deterministic
+	modes := make([]string, 0, len(target.AppModes))
+	for mode := range target.AppModes {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	for _, mode := range modes {
+		var entry protoBuffer
+		entry.stringField(1, mode)
+		entry.boolField(2, target.AppModes[mode])
+		b.message(12, entry)
+	}
+
+	b.boolField(13, target.Deprecated)
+	b.stringField(14, target.VerifyOutput)
+	b.boolField(15, target.Usable)
+	b.stringField(16, target.VerifyStatus)
+	for _, alias := range target.Aliases {
+		b.message(17, protoBuffer(alias))
+	}
+	b.int64Field(18, int64(target.Bits))
+	b.stringField(19, target.Endian)
+	b.stringField(20, target.Triple)
+	b.stringField(21, target.FailReason)
+	return b
+}
+
+func encodeTargetsResult(result TargetsResult) protoBuffer {
+	var b protoBuffer
+	for _, target := range result.Targets {
+		b.message(1, encodeTargetInfo(target))
+	}
+	b.int64Field(2, int64(result.TotalCount))
+	b.int64Field(3, int64(result.VerifiedCount))
+	b.int64Field(4, int64(result.DetectedCount))
+	b.int64Field(5, int64(result.HardcodedCount))
+	b.stringField(6, result.GeneratedAt)
+	b.boolField(7, result.VerificationRun)
+	b.boolField(8, result.NimAvailable)
+	b.boolField(9, result.DefaultThreads)
+	for _, backend := range result.Backends {
+		// Repeated strings keep empty elements, so they bypass b.stringField
+		b.message(10, protoBuffer(backend))
+	}
+	b.stringField(11, result.NimVersion)
+	b.boolField(12, result.IncrementalUsed)
+	return b
+}
+
+// outputProtobuf writes result as a length-delimited TargetsResult message.
+func outputProtobuf(w io.Writer, result TargetsResult) error {
+	message := encodeTargetsResult(result)
+	frame := binary.AppendUvarint(nil, uint64(len(message)))
+	_, err := w.Write(append(frame, message...))
+	return err
+}
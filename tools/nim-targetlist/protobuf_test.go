@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// protoField is a field declaration read from targets.proto.
+type protoField struct {
+	name     string
+	typ      string // scalar or message type; the value type for maps
+	repeated bool
+	isMap    bool
+}
+
+var protoFieldPattern = regexp.MustCompile(`^\s*(repeated\s+)?(map<\s*string\s*,\s*(\w+)\s*>|\w+)\s+(\w+)\s*=\s*(\d+)\s*;`)
+
+// loadProtoSchema reads the field declarations of every message in
+// targets.proto, keyed by message name and field number.
+func loadProtoSchema(t *testing.T) map[string]map[uint64]protoField {
+	t.Helper()
+	file, err := os.Open("targets.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	schema := make(map[string]map[uint64]protoField)
+	var message string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "message "); ok {
+			message = strings.TrimSpace(strings.TrimSuffix(name, "{"))
+			schema[message] = make(map[uint64]protoField)
+			continue
+		}
+		m := protoFieldPattern.FindStringSubmatch(line)
+		if m == nil || message == "" {
+			continue
+		}
+		number, _ := strconv.ParseUint(m[5], 10, 64)
+		field := protoField{name: m[4], typ: m[2], repeated: m[1] != ""}
+		if m[3] != "" {
+			field.typ, field.isMap = m[3], true
+		}
+		schema[message][number] = field
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// decodeProto decodes a message by the schema into a JSON-like value keyed
+// by proto field name, failing on fields or wire types the schema does not
+// declare.
+func decodeProto(t *testing.T, schema map[string]map[uint64]protoField, message string, data []byte) map[string]interface{} {
+	t.Helper()
+	fields, ok := schema[message]
+	if !ok {
+		t.Fatalf("message %s not in targets.proto", message)
+	}
+
+	decoded := make(map[string]interface{})
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		number, wireType := key>>3, int(key&7)
+		field, ok := fields[number]
+		if !ok {
+			t.Fatalf("%s: field %d is not in targets.proto", message, number)
+		}
+
+		wantWire := protoBytes
+		switch {
+		case field.isMap || field.repeated:
+		case field.typ == "bool" || field.typ == "int64":
+			wantWire = protoVarint
+		case field.typ == "double":
+			wantWire = protoFixed64
+		}
+		if wireType != wantWire {
+			t.Fatalf("%s.%s: wire type %d, want %d for %s", message, field.name, wireType, wantWire, field.typ)
+		}
+
+		var value interface{}
+		switch wireType {
+		case protoVarint:
+			v, n := binary.Uvarint(data)
+			data = data[n:]
+			if field.typ == "bool" {
+				value = v != 0
+			} else {
+				value = int64(v)
+			}
+		case protoFixed64:
+			value = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			payload := data[n : n+int(size)]
+			data = data[n+int(size):]
+			switch {
+			case field.isMap:
+				entry := decodeProto(t, map[string]map[uint64]protoField{"entry": {
+					1: {name: "key", typ: "string"},
+					2: {name: "value", typ: field.typ},
+				}}, "entry", payload)
+				entries, _ := decoded[field.name].(map[string]interface{})
+				if entries == nil {
+					entries = make(map[string]interface{})
+				}
+				key, _ := entry["key"].(string)
+				value, ok := entry["value"]
+				if !ok {
+					value = false
+				}
+				entries[key] = value
+				decoded[field.name] = entries
+				continue
+			case field.typ == "string":
+				value = string(payload)
+			default:
+				value = decodeProto(t, schema, field.typ, payload)
+			}
+		}
+
+		if field.repeated {
+			list, _ := decoded[field.name].([]interface{})
+			decoded[field.name] = append(list, value)
+		} else {
+			decoded[field.name] = value
+		}
+	}
+	return decoded
+}
+
+// fullTargetsResult sets every field, so a field missing from the encoder
+// or numbered differently from targets.proto breaks the round trip.
+func fullTargetsResult() TargetsResult {
+	return TargetsResult{
+		Targets: []TargetInfo{{
+			OS:              "linux",
+			CPU:             "arm64",
+			Verified:        true,
+			Source:          "detected",
+			Command:         "nim c --os:linux --cpu:arm64",
+			CrossOnly:       true,
+			Confidence:      0.75,
+			Backend:         "c",
+			SkipReason:      "budget_skipped",
+			BinarySizeBytes: 4242,
+			RuntimeWarning:  "requires a libc",
+			AppModes:        map[string]bool{"lib": true, "staticlib": false},
+			Deprecated:      true,
+			VerifyOutput:    "Hint: ok",
+			Usable:          true,
+			VerifyStatus:    verifyStatusVerified,
+			Aliases:         []string{"aarch64", ""},
+			Bits:            64,
+			Endian:          "little",
+			Triple:          "aarch64-linux-gnu",
+			FailReason:      "Error: unsupported",
+		}, {
+			OS:  "dos",
+			CPU: "i386",
+		}},
+		TargetsSummary: TargetsSummary{
+			TotalCount:      2,
+			VerifiedCount:   1,
+			DetectedCount:   1,
+			HardcodedCount:  1,
+			GeneratedAt:     "2026-10-16T00:00:00Z",
+			VerificationRun: true,
+			NimAvailable:    true,
+			NimVersion:      "2.0.2",
+			DefaultThreads:  true,
+			IncrementalUsed: true,
+			Backends:        []string{"c", "js"},
+		},
+	}
+}
+
+func TestFullTargetsResultSetsEveryField(t *testing.T) {
+	result := fullTargetsResult()
+	for _, v := range []reflect.Value{reflect.ValueOf(result.Targets[0]), reflect.ValueOf(result.TargetsSummary)} {
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).IsZero() {
+				t.Errorf("fullTargetsResult leaves %s.%s unset", v.Type().Name(), v.Type().Field(i).Name)
+			}
+		}
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	want := fullTargetsResult()
+	var out bytes.Buffer
+	if err := outputProtobuf(&out, want); err != nil {
+		t.Fatal(err)
+	}
+
+	size, n := binary.Uvarint(out.Bytes())
+	if n <= 0 || int(size) != out.Len()-n {
+		t.Fatalf("length prefix %d does not match the %d byte message", size, out.Len()-n)
+	}
+	decoded := decodeProto(t, loadProtoSchema(t), "TargetsResult", out.Bytes()[n:])
+
+	// The proto field names are the JSON names, so JSON carries the
+	// decoded message back into the Go types
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TargetsResult
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip through targets.proto:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestProtoSchemaMatchesStructs(t *testing.T) {
+	schema := loadProtoSchema(t)
+	check := func(message string, typ reflect.Type) {
+		names := make(map[string]bool)
+		for _, field := range schema[message] {
+			names[field.name] = true
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			tag := typ.Field(i).Tag.Get("json")
+			name, _, _ := strings.Cut(tag, ",")
+			if typ.Field(i).Anonymous || name == "" {
+				continue
+			}
+			if !names[name] {
+				t.Errorf("%s.%s has no %s field in targets.proto", typ.Name(), typ.Field(i).Name, name)
+			}
+			delete(names, name)
+		}
+		for name := range names {
+			if message == "TargetsResult" && name == "targets" {
+				continue
+			}
+			t.Errorf("targets.proto %s.%s has no Go field", message, name)
+		}
+	}
+	check("TargetInfo", reflect.TypeOf(TargetInfo{}))
+	check("TargetsResult", reflect.TypeOf(TargetsSummary{}))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KnownTargets is the shape of a target list document: the OS and CPU
+// names nim is known to accept.
+type KnownTargets struct {
+	OSes []string `json:"oses"`
+	CPUs []string `json:"cpus"`
+}
+
+const remoteListTimeout = 10 * time.Second
+
+// remoteListKeyData is the base64 ed25519 public key published target
+// lists are signed with, checked against --remote-list-sig.
+//
+//go:embed remote_list_key.pub
+var remoteListKeyData string
+
+var errBadSignature = errors.New("signature verification failed")
+
+// fetchRemoteList downloads a KnownTargets document from url. With a
+// non-empty sigURL the document must carry a valid detached signature
+// from there, or it is rejected. Names are lowercased and trimmed; empty
+// entries are dropped.
+func fetchRemoteList(url, sigURL string) (*KnownTargets, error) {
+	client := &http.Client{Timeout: remoteListTimeout}
+
+	body, err := fetchRemote(client, url)
+	if err != nil {
+		return nil, err
+	}
+	if sigURL != "" {
+		sig, err := fetchRemote(client, sigURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetching signature: %v", err)
+		}
+		if err := verifyRemoteList(body, sig); err != nil {
+			return nil, err
+		}
+	}
+
+	var list KnownTargets
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("invalid target list: %v", err)
+	}
+
+	list.OSes = normalizeNames(list.OSes)
+	list.CPUs = normalizeNames(list.CPUs)
+	return &list, nil
+}
+
+// fetchRemote downloads a small document from url.
+func fetchRemote(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	// Target lists are tiny; refuse anything unreasonably large.
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// verifyRemoteList checks sig, an ed25519 signature over the exact bytes
+// of list, against the bundled key. The signature may be raw or base64.
+func verifyRemoteList(list, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(remoteListKeyData))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid bundled remote list key")
+	}
+
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig)))
+		if err != nil {
+			return fmt.Errorf("invalid signature encoding: %v", err)
+		}
+		sig = decoded
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(ed25519.PublicKey(key), list, sig) {
+		return errBadSignature
+	}
+	return nil
+}
+
+func normalizeNames(names []string) []string {
+	var out []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// withRemoteListKey swaps the bundled key for a fresh one for the duration
+// of the test and returns its private half for signing.
+func withRemoteListKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := remoteListKeyData
+	remoteListKeyData = base64.StdEncoding.EncodeToString(public) + "\n"
+	t.Cleanup(func() { remoteListKeyData = saved })
+	return private
+}
+
+func TestFetchRemoteListSignature(t *testing.T) {
+	key := withRemoteListKey(t)
+	list := []byte(`{"oses": ["Linux", "zephyr"], "cpus": ["amd64"]}`)
+	tampered := []byte(`{"oses": ["linux", "evil"], "cpus": ["amd64"]}`)
+	signature := ed25519.Sign(key, list)
+
+	documents := map[string][]byte{
+		"/list.json":     list,
+		"/tampered.json": tampered,
+		"/list.sig":      []byte(base64.StdEncoding.EncodeToString(signature) + "\n"),
+		"/list.sig.raw":  signature,
+		"/garbage.sig":   []byte("not a signature"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := documents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		list, sig string
+		ok        bool
+	}{
+		{"/list.json", "/list.sig", true},
+		{"/list.json", "/list.sig.raw", true},
+		{"/list.json", "", true}, // no signature asked for
+		{"/tampered.json", "/list.sig", false},
+		{"/list.json", "/garbage.sig", false},
+		{"/list.json", "/missing.sig", false},
+	}
+	for _, test := range tests {
+		sigURL := ""
+		if test.sig != "" {
+			sigURL = server.URL + test.sig
+		}
+		got, err := fetchRemoteList(server.URL+test.list, sigURL)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%s signed by %s was accepted", test.list, test.sig)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s signed by %s: %v", test.list, test.sig, err)
+			continue
+		}
+		if want := (&KnownTargets{OSes: []string{"linux", "zephyr"}, CPUs: []string{"amd64"}}); !reflect.DeepEqual(got, want) {
+			t.Errorf("fetchRemoteList() = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestBundledRemoteListKey(t *testing.T) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(remoteListKeyData))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		t.Errorf("remote_list_key.pub is not a base64 ed25519 public key: %v", err)
+	}
+}
+
+func TestScanTargetsMergesRemoteList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"oses": ["zephyr", "linux", "../evil"], "cpus": ["xtensa"]}`))
+	}))
+	defer server.Close()
+
+	ts := NewTargetScanner()
+	ts.nimBinary = filepath.Join(t.TempDir(), "no-nim")
+	ts.remoteListURL = server.URL
+	targets := ts.scanTargets(context.Background())
+
+	sources := make(map[string]string)
+	for _, target := range targets {
+		sources[target.OS+"/"+target.CPU] = target.Source
+	}
+	// linux is built in too, but the remote list outranks the built-in one
+	for _, pair := range []string{"zephyr/amd64", "zephyr/xtensa", "linux/xtensa", "linux/amd64"} {
+		if source, ok := sources[pair]; !ok || source != "external" {
+			t.Errorf("%s: source %q (present %v), want external", pair, source, ok)
+		}
+	}
+	if source := sources["windows/amd64"]; source != "hardcoded" {
+		t.Errorf("windows/amd64: source %q, want hardcoded", source)
+	}
+	for pair := range sources {
+		if strings.Contains(pair, "evil") {
+			t.Errorf("invalid remote name merged: %s", pair)
+		}
+	}
+	if !strings.Contains(strings.Join(ts.Warnings(), "\n"), `ignoring invalid OS name "../evil"`) {
+		t.Errorf("no warning for the invalid name: %q", ts.Warnings())
+	}
+}
+
+func TestSourcePriorityDecidesMerge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"oses": ["redox", "linux"], "cpus": []}`))
+	}))
+	defer server.Close()
+
+	ts := NewTargetScanner()
+	ts.nimBinary = filepath.Join(t.TempDir(), "no-nim")
+	ts.remoteListURL = server.URL
+	ts.sourcePriority = []string{"hardcoded", "external", "detected"}
+	sources := make(map[string]string)
+	for _, target := range ts.scanTargets(context.Background()) {
+		sources[target.OS+"/"+target.CPU] = target.Source
+	}
+	// Built in as well, linux now keeps its hardcoded source; only redox
+	// is external
+	want := map[string]string{"linux/amd64": "hardcoded", "redox/amd64": "external", "windows/amd64": "hardcoded"}
+	for pair, source := range want {
+		if sources[pair] != source {
+			t.Errorf("%s: source %q, want %s", pair, sources[pair], source)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import "strings"
+
+// parseRequiredTargets parses --require values, written os:cpu. The os/cpu
+// form used by other flags is accepted as well.
+func parseRequiredTargets(specs []string) ([][2]string, error) {
+	var required [][2]string
+	for _, spec := range specs {
+		osName, cpu, err := parseTargetSpec(strings.Replace(spec, ":", "/", 1))
+		if err != nil {
+			return nil, err
+		}
+		required = append(required, [2]string{osName, cpu})
+	}
+	return required, nil
+}
+
+// missingRequired returns the required targets, as os:cpu, that are not in
+// targets or did not verify.
+func missingRequired(targets []TargetInfo, required [][2]string) []string {
+	verified := make(map[[2]string]bool, len(targets))
+	for _, target := range targets {
+		if target.Verified {
+			verified[[2]string{target.OS, target.CPU}] = true
+		}
+	}
+
+	var missing []string
+	for _, target := range required {
+		if !verified[target] {
+			missing = append(missing, target[0]+":"+target[1])
+		}
+	}
+	return missing
+}
+
+// missingRequiredInAll is missingRequired for several verification runs of
+// the same targets, such as one per compared backend: a required target
+// has to verify in every run.
+func missingRequiredInAll(runs [][]TargetInfo, required [][2]string) []string {
+	missingIn := make(map[string]bool)
+	for _, run := range runs {
+		for _, target := range missingRequired(run, required) {
+			missingIn[target] = true
+		}
+	}
+
+	var missing []string
+	for _, target := range required {
+		if name := target[0] + ":" + target[1]; missingIn[name] {
+			missing = append(missing, name)
+			delete(missingIn, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// osScore is the share of an OS's CPUs that verified.
+type osScore struct {
+	OS       string
+	Verified int
+	Total    int
+}
+
+func (s osScore) Ratio() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Verified) / float64(s.Total)
+}
+
+// osScores computes, per OS, how many of the CPUs it is listed with
+// verified. Targets that were never compiled count as not verified, so the
+// scores are only meaningful after --verify-all.
+func osScores(targets []TargetInfo) []osScore {
+	index := make(map[string]int)
+	var scores []osScore
+	for _, target := range targets {
+		i, ok := index[target.OS]
+		if !ok {
+			i = len(scores)
+			index[target.OS] = i
+			scores = append(scores, osScore{OS: target.OS})
+		}
+		scores[i].Total++
+		if target.Verified {
+			scores[i].Verified++
+		}
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].OS < scores[j].OS })
+	return scores
+}
+
+// outputOSScores writes the scores as a JSON object mapping each OS to its
+// ratio, or as a table with the counts behind each ratio.
+func outputOSScores(w io.Writer, scores []osScore, format string) error {
+	switch format {
+	case "json":
+		ratios := make(map[string]float64, len(scores))
+		for _, s := range scores {
+			ratios[s.OS] = s.Ratio()
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ratios)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "OS\tVerified\tTotal\tScore")
+		fmt.Fprintln(tw, "──\t────────\t─────\t─────")
+		for _, s := range scores {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f\n", s.OS, s.Verified, s.Total, s.Ratio())
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("--os-scores does not support format %q", format)
+	}
+}
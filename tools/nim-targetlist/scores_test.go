@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOSScores(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "windows", CPU: "amd64", Verified: true},
+		{OS: "linux", CPU: "amd64", Verified: true},
+		{OS: "linux", CPU: "arm64", Verified: true},
+		{OS: "linux", CPU: "mips"},
+		{OS: "haiku", CPU: "amd64", VerifyStatus: verifyStatusSkipped},
+	}
+	scores := osScores(targets)
+	want := []osScore{
+		{OS: "haiku", Verified: 0, Total: 1},
+		{OS: "linux", Verified: 2, Total: 3},
+		{OS: "windows", Verified: 1, Total: 1},
+	}
+	if !reflect.DeepEqual(scores, want) {
+		t.Fatalf("osScores() = %+v, want %+v", scores, want)
+	}
+	if (osScore{}).Ratio() != 0 {
+		t.Error("an OS without targets has a non-zero ratio")
+	}
+
+	var buf bytes.Buffer
+	if err := outputOSScores(&buf, scores, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var ratios map[string]float64
+	if err := json.Unmarshal(buf.Bytes(), &ratios); err != nil {
+		t.Fatal(err)
+	}
+	if wantRatios := map[string]float64{"haiku": 0, "linux": 2.0 / 3, "windows": 1}; !reflect.DeepEqual(ratios, wantRatios) {
+		t.Errorf("ratios %v, want %v", ratios, wantRatios)
+	}
+
+	buf.Reset()
+	if err := outputOSScores(&buf, scores, "table"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if got := strings.Fields(lines[3]); !reflect.DeepEqual(got, []string{"linux", "2", "3", "0.67"}) {
+		t.Errorf("linux row %q in\n%s", got, buf.String())
+	}
+
+	if err := outputOSScores(&buf, scores, "csv"); err == nil {
+		t.Error("no error for an unsupported format")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// measureBinarySize fully compiles the test program for a target and returns
+// the size of the produced executable. Only the host target can be linked
+// without a cross toolchain, so callers restrict this to it.
+func (ts *TargetScanner) measureBinarySize(ctx context.Context, osName, cpu string) (int64, error) {
+	tmpDir, err := os.MkdirTemp("", "nim-targetlist-size-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	binary := filepath.Join(tmpDir, "probe")
+	args := []string{"c"}
+	for _, arg := range ts.verifyArgs(osName, cpu) {
+		switch arg {
+		case "--compileOnly":
+			continue
+		case "-":
+			args = append(args, "--nimcache:"+filepath.Join(tmpDir, "cache"), "-o:"+binary)
+		}
+		args = append(args, arg)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ts.verifyTimeout(osName, cpu))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ts.nimBinary, args...)
+	cmd.Stdin = strings.NewReader(ts.testProgram(osName, cpu))
+	cmd.Env = ts.compileEnv()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	info, err := os.Stat(binary)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// sizeNim links a 4096-byte "binary" wherever -o: points.
+const sizeNim = `cat >/dev/null
+for arg; do
+	case "$arg" in -o:*) head -c 4096 /dev/zero >"${arg#-o:}" ;; esac
+done
+`
+
+func TestMeasureSizeOnlyForHost(t *testing.T) {
+	ts, calls := recordingNimScanner(t, sizeNim)
+	ts.verifyAll = true
+	ts.measureSize = true
+	hostOS, hostCPU := ts.getHostTarget()
+	other := "arm64"
+	if hostCPU == other {
+		other = "amd64"
+	}
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: hostOS, CPU: hostCPU},
+		{OS: hostOS, CPU: other},
+	})
+
+	if got := targets[0].BinarySizeBytes; got != 4096 {
+		t.Errorf("host binary size %d, want 4096", got)
+	}
+	if got := targets[1].BinarySizeBytes; got != 0 {
+		t.Errorf("cross target measured at %d bytes", got)
+	}
+
+	var linked []string
+	for _, call := range calls() {
+		if !strings.Contains(call, "--compileOnly") {
+			linked = append(linked, call)
+		}
+	}
+	if len(linked) != 1 || !strings.HasPrefix(linked[0], "c --os:"+hostOS+" --cpu:"+hostCPU+" ") || !strings.Contains(linked[0], " -o:") {
+		t.Errorf("linking compiles: %q", linked)
+	}
+}
+
+func TestMeasureSizeFailure(t *testing.T) {
+	ts := stubNimScanner(t, "cat >/dev/null\necho 'Error: linker failed'; exit 1\n")
+	hostOS, hostCPU := ts.getHostTarget()
+	if _, err := ts.measureBinarySize(context.Background(), hostOS, hostCPU); err == nil || !strings.Contains(err.Error(), "linker failed") {
+		t.Errorf("error %v, want nim's output", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errVerifyTimeout is wrapped around the error of a compile that ran out of
+// time, as opposed to being cancelled.
+var errVerifyTimeout = errors.New("timed out")
+
+// stdinReadErrors are what nim prints when it cannot read the program
+// piped to it as "-".
+var stdinReadErrors = []string{"cannot open '-'", "cannot open file: -", "cannot read from stdin"}
+
+// execVerify runs nim with args, feeding stdin to it. stdinFailed reports
+// that the program could not be handed over through the pipe, as opposed
+// to nim running and rejecting the target.
+func (ts *TargetScanner) execVerify(ctx context.Context, args []string, stdin io.Reader, timeout time.Duration) (output []byte, stdinFailed bool, err error) {
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ts.nimBinary, args...)
+	cmd.Stdin = stdin
+	cmd.Env = ts.compileEnv()
+	output, err = cmd.CombinedOutput()
+	if err != nil && ctx.Err() != nil && parent.Err() == nil {
+		return output, false, fmt.Errorf("%w after %s (%w)", errVerifyTimeout, timeout, err)
+	}
+	if err == nil || ctx.Err() != nil {
+		return output, false, err
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// nim ran to completion but copying the program into it failed
+		return output, cmd.ProcessState != nil, err
+	}
+	lower := strings.ToLower(string(output))
+	for _, message := range stdinReadErrors {
+		if strings.Contains(lower, message) {
+			return output, true, err
+		}
+	}
+	return output, false, err
+}
+
+// runVerifyFile compiles program from a temporary file instead of stdin.
+// args must end with the "-" placeholder, which is replaced by the file.
+// The file gets a fixed name in its own directory since nim derives the
+// module name from it. With --keep-artifacts the file is kept in the
+// target's artifact directory.
+func (ts *TargetScanner) runVerifyFile(ctx context.Context, args []string, program string, timeout time.Duration) ([]string, []byte, error) {
+	var dir string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--nimcache:") && ts.artifactsDir != "" {
+			dir = strings.TrimPrefix(arg, "--nimcache:")
+		}
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var err error
+		if dir, err = os.MkdirTemp("", programName+"-"); err != nil {
+			return nil, nil, err
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	path := filepath.Join(dir, "verify.nim")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		return nil, nil, err
+	}
+
+	fileArgs := append(append([]string(nil), args[:len(args)-1]...), path)
+	output, _, err := ts.execVerify(ctx, fileArgs, nil, timeout)
+	ts.noteExecError(ctx, fileArgs, err)
+	return append([]string{ts.nimBinary}, fileArgs...), output, err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStdinFallback(t *testing.T) {
+	// This nim cannot read from a pipe, but compiles files
+	ts, calls := recordingNimScanner(t, `for last; do :; done
+if [ "$last" = - ]; then echo "Error: cannot open '-'"; exit 1; fi
+cat "$last"
+`)
+	ts.RetainVerifyOutput = true
+	targets := ts.verifyTargets(context.Background(), []TargetInfo{
+		{OS: "linux", CPU: "amd64", Backend: "c"},
+		{OS: "linux", CPU: "arm64", Backend: "c"},
+	})
+
+	for _, target := range targets {
+		if !target.Verified || target.VerifyOutput != ts.testProgram(target.OS, target.CPU) {
+			t.Errorf("%s/%s: verified %v from %q", target.OS, target.CPU, target.Verified, target.VerifyOutput)
+		}
+	}
+	if !ts.stdinFallback.Load() {
+		t.Error("the fallback was not recorded")
+	}
+
+	got := calls()
+	if len(got) != 3 {
+		t.Fatalf("nim ran %d times, want 3: %q", len(got), got)
+	}
+	// Only the first compile tries stdin; the rest go straight to a file
+	for i, call := range got {
+		piped := strings.HasSuffix(call, " -")
+		if piped != (i == 0) {
+			t.Errorf("call %d: %q", i, call)
+		}
+		if !piped && !strings.HasSuffix(call, "/verify.nim") {
+			t.Errorf("call %d does not compile verify.nim: %q", i, call)
+		}
+	}
+	if warnings := ts.Warnings(); len(warnings) != 0 {
+		t.Errorf("the fallback was warned about: %q", warnings)
+	}
+}
+
+func TestStdinFailureIsNotFallback(t *testing.T) {
+	ts, calls := recordingNimScanner(t, "cat >/dev/null\necho 'Error: unknown OS'; exit 1\n")
+	ts.verifyTarget(context.Background(), "linux", "amd64", "c")
+	if ts.stdinFallback.Load() || len(calls()) != 1 {
+		t.Errorf("a rejected target switched to files after %d runs", len(calls()))
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// ttyColumns always returns 0 where the terminal size cannot be queried,
+// leaving $COLUMNS to decide.
+func ttyColumns(f *os.File) int {
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+// gnuTriples maps os/cpu targets to the GNU-style triple toolchains and
+// packaging systems know them by. Only targets with one well-established
+// triple are listed; everything else has no Triple.
+var gnuTriples = map[string]string{
+	"linux/amd64":       "x86_64-linux-gnu",
+	"linux/i386":        "i686-linux-gnu",
+	"linux/arm":         "arm-linux-gnueabihf",
+	"linux/arm64":       "aarch64-linux-gnu",
+	"linux/riscv32":     "riscv32-linux-gnu",
+	"linux/riscv64":     "riscv64-linux-gnu",
+	"linux/powerpc":     "powerpc-linux-gnu",
+	"linux/powerpc64":   "powerpc64-linux-gnu",
+	"linux/powerpc64el": "powerpc64le-linux-gnu",
+	"linux/mips":        "mips-linux-gnu",
+	"linux/mipsel":      "mipsel-linux-gnu",
+	"linux/mips64":      "mips64-linux-gnuabi64",
+	"linux/mips64el":    "mips64el-linux-gnuabi64",
+	"linux/sparc64":     "sparc64-linux-gnu",
+	"linux/alpha":       "alpha-linux-gnu",
+	"linux/m68k":        "m68k-linux-gnu",
+	"linux/hppa":        "hppa-linux-gnu",
+	"linux/ia64":        "ia64-linux-gnu",
+	"linux/loongarch64": "loongarch64-linux-gnu",
+	"windows/amd64":     "x86_64-w64-mingw32",
+	"windows/i386":      "i686-w64-mingw32",
+	"windows/arm64":     "aarch64-w64-mingw32",
+	"macosx/amd64":      "x86_64-apple-darwin",
+	"macosx/arm64":      "aarch64-apple-darwin",
+	"ios/arm64":         "aarch64-apple-ios",
+	"android/arm":       "armv7a-linux-androideabi",
+	"android/arm64":     "aarch64-linux-android",
+	"android/i386":      "i686-linux-android",
+	"android/amd64":     "x86_64-linux-android",
+	"freebsd/amd64":     "x86_64-unknown-freebsd",
+	"freebsd/i386":      "i386-unknown-freebsd",
+	"freebsd/arm64":     "aarch64-unknown-freebsd",
+	"netbsd/amd64":      "x86_64-unknown-netbsd",
+	"openbsd/amd64":     "x86_64-unknown-openbsd",
+	"haiku/amd64":       "x86_64-unknown-haiku",
+	"standalone/wasm32": "wasm32-unknown-unknown",
+}
+
+// filterWithTriple returns the targets that have a Triple, in their
+// original order.
+func filterWithTriple(targets []TargetInfo) []TargetInfo {
+	var kept []TargetInfo
+	for _, target := range targets {
+		if target.Triple != "" {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
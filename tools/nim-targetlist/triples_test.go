@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGNUTriples(t *testing.T) {
+	targets := scanHardcoded(t, NewTargetScanner())
+	for name, triple := range gnuTriples {
+		target, ok := targets[name]
+		if !ok {
+			t.Errorf("%s has a triple but is not a target", name)
+			continue
+		}
+		if target.Triple != triple {
+			t.Errorf("%s: triple %q, want %q", name, target.Triple, triple)
+		}
+	}
+	if target, ok := targets["haiku/arm64"]; !ok || target.Triple != "" {
+		t.Errorf("haiku/arm64 (scanned %v) has triple %q", ok, target.Triple)
+	}
+}
+
+func TestFilterWithTriple(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "linux", CPU: "amd64", Triple: "x86_64-linux-gnu"},
+		{OS: "haiku", CPU: "arm64"},
+		{OS: "windows", CPU: "i386", Triple: "i686-w64-mingw32"},
+	}
+	want := []TargetInfo{targets[0], targets[2]}
+	if got := filterWithTriple(targets); !reflect.DeepEqual(got, want) {
+		t.Errorf("filterWithTriple() = %+v, want %+v", got, want)
+	}
+}
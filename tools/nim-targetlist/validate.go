@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// validateResultFile re-verifies every target listed in a previously saved
+// JSON result and reports targets whose verification outcome changed. It
+// returns the number of regressions: targets that used to verify but no
+// longer do. Targets the archived run did not attempt are only re-checked,
+// since there is no earlier outcome to compare with.
+func (ts *TargetScanner) validateResultFile(ctx context.Context, w io.Writer, path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var archived TargetsResult
+	if err := json.Unmarshal(data, &archived); err != nil {
+		return 0, fmt.Errorf("invalid result file %s: %v", path, err)
+	}
+
+	ts.detectNim(ctx)
+	if !ts.nimAvailable {
+		return 0, fmt.Errorf("nim command not available")
+	}
+
+	current := make([]TargetInfo, len(archived.Targets))
+	for i, target := range archived.Targets {
+		// Results written before targets recorded their backend need the
+		// one verification would pick today, or nim gets no command at all
+		backend := target.Backend
+		if backend == "" {
+			backend = ts.backendFor(target.OS, target.CPU)
+		}
+		current[i] = TargetInfo{
+			OS:      target.OS,
+			CPU:     target.CPU,
+			Backend: backend,
+			Source:  target.Source,
+			Command: target.Command,
+		}
+	}
+
+	// Every archived target is re-checked, not just the common ones
+	ts.verifyAll = true
+	ts.cache = nil // a cached result would hide the regression being looked for
+	ts.skipVerify = false
+	ts.hardcodedOnly = false
+	current = ts.verifyTargets(ctx, current)
+
+	var regressions, improvements []TargetInfo
+	for i, target := range archived.Targets {
+		switch archivedStatus(target) {
+		case verifyStatusVerified:
+			if current[i].VerifyStatus == verifyStatusFailed {
+				regressions = append(regressions, current[i])
+			}
+		case verifyStatusFailed:
+			if current[i].VerifyStatus == verifyStatusVerified {
+				improvements = append(improvements, current[i])
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Validated %d targets from %s\n", len(current), path)
+	fmt.Fprintf(w, "Regressions (previously verified, now failing): %d\n", len(regressions))
+	for _, target := range regressions {
+		fmt.Fprintf(w, "  - %s/%s\n", target.OS, target.CPU)
+	}
+	fmt.Fprintf(w, "Improvements (previously failing, now verified): %d\n", len(improvements))
+	for _, target := range improvements {
+		fmt.Fprintf(w, "  + %s/%s\n", target.OS, target.CPU)
+	}
+
+	return len(regressions), nil
+}
+
+// archivedStatus is the verification outcome recorded for an archived
+// target. Results written before verify_status existed only say whether a
+// target verified, which is enough to know it was attempted.
+func archivedStatus(target TargetInfo) string {
+	if target.VerifyStatus == "" && target.Verified {
+		return verifyStatusVerified
+	}
+	return target.VerifyStatus
+}
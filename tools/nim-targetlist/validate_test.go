@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateResultFileReportsDivergence(t *testing.T) {
+	// The stub needs a backend command, like nim, and rejects dos
+	ts := stubNimScanner(t, `case "$1" in --version) echo "Nim Compiler Version 2.0.2"; exit 0;; esac
+cat >/dev/null
+backend=no
+for arg; do
+	case "$arg" in
+	c|js) backend=yes;;
+	--os:dos) echo "Error: unsupported"; exit 1;;
+	esac
+done
+[ $backend = yes ]
+`)
+	// No backend fields, as written before targets recorded them
+	archive := `{"targets": [
+		{"os": "linux", "cpu": "amd64", "verified": true, "source": "detected"},
+		{"os": "dos", "cpu": "i386", "verified": true, "source": "detected"},
+		{"os": "freebsd", "cpu": "amd64", "verified": false, "verify_status": "failed", "source": "detected"}
+	]}`
+	path := filepath.Join(t.TempDir(), "targets.json")
+	if err := os.WriteFile(path, []byte(archive), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var report bytes.Buffer
+	regressions, err := ts.validateResultFile(context.Background(), &report, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regressions != 1 {
+		t.Errorf("regressions = %d, want 1\n%s", regressions, report.String())
+	}
+	for _, line := range []string{"  - dos/i386\n", "  + freebsd/amd64\n"} {
+		if !strings.Contains(report.String(), line) {
+			t.Errorf("report lacks %q:\n%s", line, report.String())
+		}
+	}
+	if strings.Contains(report.String(), "linux/amd64") {
+		t.Errorf("linux/amd64 still verifies but is reported:\n%s", report.String())
+	}
+}
+
+func TestValidateResultFileIgnoresSkipped(t *testing.T) {
+	ts := stubNimScanner(t, `case "$1" in --version) echo "Nim Compiler Version 2.0.2"; exit 0;; esac
+cat >/dev/null
+`)
+	// A default run only attempts the common targets; the rest are skipped,
+	// and verifying them now is neither a regression nor an improvement
+	archive := `{"targets": [
+		{"os": "linux", "cpu": "amd64", "verified": true, "verify_status": "verified", "source": "detected"},
+		{"os": "netbsd", "cpu": "riscv64", "verified": false, "verify_status": "skipped", "source": "detected"},
+		{"os": "haiku", "cpu": "amd64", "verified": false, "source": "detected"}
+	]}`
+	path := filepath.Join(t.TempDir(), "targets.json")
+	if err := os.WriteFile(path, []byte(archive), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var report bytes.Buffer
+	regressions, err := ts.validateResultFile(context.Background(), &report, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regressions != 0 {
+		t.Errorf("regressions = %d, want 0\n%s", regressions, report.String())
+	}
+	if !strings.Contains(report.String(), "Improvements (previously failing, now verified): 0\n") {
+		t.Errorf("skipped targets reported as improvements:\n%s", report.String())
+	}
+}
+
+func TestValidateResultFileCancelled(t *testing.T) {
+	ts := stubNimScanner(t, `case "$1" in --version) echo "Nim Compiler Version 2.0.2"; exit 0;; esac
+exec sleep 5
+`)
+	archive := `{"targets": [{"os": "linux", "cpu": "amd64", "verified": true, "verify_status": "verified", "source": "detected"}]}`
+	path := filepath.Join(t.TempDir(), "targets.json")
+	if err := os.WriteFile(path, []byte(archive), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted compile did not fail, so it is no regression
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var report bytes.Buffer
+	start := time.Now()
+	regressions, err := ts.validateResultFile(ctx, &report, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("validation took %v after cancellation", elapsed)
+	}
+	if regressions != 0 {
+		t.Errorf("regressions = %d, want 0\n%s", regressions, report.String())
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// warnf logs a warning and records it for --strict-exit. It is safe to call
+// from verification workers.
+func (ts *TargetScanner) warnf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Printf("Warning: %s", msg)
+
+	ts.warningsMu.Lock()
+	ts.warnings = append(ts.warnings, msg)
+	ts.warningsMu.Unlock()
+}
+
+// noteExecError records a warning when nim could not be run to completion:
+// it failed to start or was killed, for example by a timeout. A normal
+// non-zero exit is how nim reports an unsupported target and is not a
+// warning, and neither is a compile killed because ctx, the run, was
+// interrupted.
+func (ts *TargetScanner) noteExecError(ctx context.Context, args []string, err error) {
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return
+	}
+	ts.warnf("exec error running nim %s: %v", strings.Join(args, " "), err)
+}
+
+// Warnings returns the warnings recorded so far, in the order they occurred.
+func (ts *TargetScanner) Warnings() []string {
+	ts.warningsMu.Lock()
+	defer ts.warningsMu.Unlock()
+	return append([]string(nil), ts.warnings...)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterruptedCompilesAreNotWarnings(t *testing.T) {
+	ts := stubNimScanner(t, "exec sleep 5\n")
+	ts.verifyAll = true
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	ts.verifyTargets(ctx, poolTargets()[:8])
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("verification took %v after the interrupt", elapsed)
+	}
+	if warnings := ts.Warnings(); len(warnings) != 0 {
+		t.Errorf("killed compiles were recorded as warnings: %q", warnings)
+	}
+
+	// The same goes for the nim queries made while scanning
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if oses, _, err := ts.readPlatformTables(ctx); err == nil {
+		t.Errorf("readPlatformTables() = %v with a cancelled context", oses)
+	}
+	if cpus := ts.probeCPUsPerOS(ctx, []string{"linux"}); cpus != nil {
+		t.Errorf("probeCPUsPerOS() = %v with a cancelled context", cpus)
+	}
+	if warnings := ts.Warnings(); len(warnings) != 0 {
+		t.Errorf("cancelled queries were recorded as warnings: %q", warnings)
+	}
+}
+
+func TestTimedOutCompileIsWarning(t *testing.T) {
+	ts := stubNimScanner(t, "exec sleep 5\n")
+	ts.timeout = 100 * time.Millisecond
+	ts.verifyTarget(context.Background(), "linux", "amd64", "c")
+	if warnings := ts.Warnings(); len(warnings) != 1 {
+		t.Errorf("warnings = %q, want one for the timeout", warnings)
+	}
+}
+
+func TestStrictExitFailsOnWarnings(t *testing.T) {
+	// The unknown OS is warned about and otherwise ignored
+	args := []string{"--hardcoded-only", "--os", "linux,plan10", "--format", "json"}
+	if _, stderr, code := runMain(t, "", args...); code != 0 {
+		t.Fatalf("exit status %d without --strict-exit\n%s", code, stderr)
+	}
+
+	_, stderr, code := runMain(t, "", append(args, "--strict-exit")...)
+	if code != 1 {
+		t.Errorf("exit status %d with --strict-exit, want 1\n%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "--strict-exit: failing because of 1 warning(s)") || !strings.Contains(stderr, `unknown OS "plan10"`) {
+		t.Errorf("stderr does not list the warning:\n%s", stderr)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ComplexityWeights scales the per-target verification timeout. A target's
+// weight is the product of its OS and CPU weights; unlisted names weigh 1.
+type ComplexityWeights struct {
+	OS  map[string]float64 `json:"os"`
+	CPU map[string]float64 `json:"cpu"`
+}
+
+// defaultComplexityWeights reflects which cross targets are noticeably
+// slower to compile for than mainstream desktop platforms.
+func defaultComplexityWeights() *ComplexityWeights {
+	return &ComplexityWeights{
+		OS: map[string]float64{
+			"standalone":     1.5,
+			"nintendoswitch": 1.5,
+			"freertos":       1.5,
+			"zephyr":         1.5,
+			"nuttx":          1.5,
+			"genode":         1.5,
+		},
+		CPU: map[string]float64{
+			"wasm32":      2.0,
+			"esp":         2.0,
+			"avr":         1.5,
+			"msp430":      1.5,
+			"e2k":         1.5,
+			"riscv32":     1.5,
+			"loongarch64": 1.5,
+		},
+	}
+}
+
+// loadComplexityWeights reads weights from a JSON file and overlays them on
+// the defaults.
+func loadComplexityWeights(path string) (*ComplexityWeights, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var custom ComplexityWeights
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("invalid weights file %s: %v", path, err)
+	}
+
+	weights := defaultComplexityWeights()
+	for name, w := range custom.OS {
+		if w <= 0 {
+			return nil, fmt.Errorf("invalid weight %v for os %q: must be positive", w, name)
+		}
+		weights.OS[strings.ToLower(name)] = w
+	}
+	for name, w := range custom.CPU {
+		if w <= 0 {
+			return nil, fmt.Errorf("invalid weight %v for cpu %q: must be positive", w, name)
+		}
+		weights.CPU[strings.ToLower(name)] = w
+	}
+	return weights, nil
+}
+
+func (cw *ComplexityWeights) weight(osName, cpu string) float64 {
+	w := 1.0
+	if v, ok := cw.OS[osName]; ok {
+		w *= v
+	}
+	if v, ok := cw.CPU[cpu]; ok {
+		w *= v
+	}
+	return w
+}
+
+// scaleTimeout multiplies base by the weight of the os/cpu pair.
+func (cw *ComplexityWeights) scaleTimeout(base time.Duration, osName, cpu string) time.Duration {
+	return time.Duration(float64(base) * cw.weight(osName, cpu))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeWeights(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "weights.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadComplexityWeights(t *testing.T) {
+	weights, err := loadComplexityWeights(writeWeights(t, `{"os": {"Linux": 2}, "cpu": {"wasm32": 3}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		os, cpu string
+		want    float64
+	}{
+		{"linux", "amd64", 2},
+		{"linux", "wasm32", 6},
+		{"standalone", "avr", 2.25},
+		{"windows", "amd64", 1},
+	}
+	for _, tt := range tests {
+		if got := weights.weight(tt.os, tt.cpu); got != tt.want {
+			t.Errorf("weight(%s, %s) = %v, want %v", tt.os, tt.cpu, got, tt.want)
+		}
+	}
+
+	ts := NewTargetScanner()
+	ts.timeout = 10 * time.Second
+	if got := ts.verifyTimeout("linux", "wasm32"); got != 10*time.Second {
+		t.Errorf("unweighted timeout %v, want the base timeout", got)
+	}
+	ts.timeoutWeights = weights
+	if got := ts.verifyTimeout("linux", "wasm32"); got != time.Minute {
+		t.Errorf("weighted timeout %v, want 1m0s", got)
+	}
+}
+
+func TestLoadComplexityWeightsRejectsBadFiles(t *testing.T) {
+	tests := map[string]string{
+		`{"os": {"linux": 0}}`: "must be positive",
+		`{"cpu": {"arm": -1}}`: "must be positive",
+		`{"os": ["linux"]}`:    "invalid weights file",
+	}
+	for content, want := range tests {
+		_, err := loadComplexityWeights(writeWeights(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", content, err, want)
+		}
+	}
+	if _, err := loadComplexityWeights(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
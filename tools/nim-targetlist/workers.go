@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// defaultWorkers is the number of parallel verification compiles used
+// unless --workers or --concurrency says otherwise.
+const defaultWorkers = 8
+
+// maxWorkers caps --workers; beyond this nim processes only contend for
+// memory and disk.
+const maxWorkers = 256
+
+// compileMemoryEstimate is roughly the peak memory of one nim compile plus
+// its C compiler for the small verification program.
+const compileMemoryEstimate = 512 << 20
+
+// autoWorkers sizes the verification pool for a machine with the given CPU
+// count and available memory: one compile per CPU, but no more than fit in
+// memory. availableBytes of 0 means unknown and only the CPU count applies.
+func autoWorkers(cpus int, availableBytes uint64) int {
+	workers := cpus
+	if availableBytes > 0 {
+		if byMemory := int(availableBytes / compileMemoryEstimate); byMemory < workers {
+			workers = byMemory
+		}
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// availableMemory returns MemAvailable from /proc/meminfo, or 0 where that
+// is not available.
+func availableMemory() uint64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// parseConcurrency resolves the --concurrency value to a worker count.
+func parseConcurrency(value string) (int, bool) {
+	switch value {
+	case "":
+		return defaultWorkers, true
+	case "auto":
+		return autoWorkers(runtime.NumCPU(), availableMemory()), true
+	}
+	return 0, false
+}
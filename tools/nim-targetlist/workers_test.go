@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestAutoWorkers(t *testing.T) {
+	tests := []struct {
+		cpus      int
+		available uint64
+		want      int
+	}{
+		{8, 0, 8},        // memory unknown
+		{8, 64 << 30, 8}, // plenty of memory
+		{16, 4 << 30, 8}, // 4 GiB fits 8 compiles
+		{16, 4<<30 + compileMemoryEstimate - 1, 8}, // partial compiles do not count
+		{4, 100 << 20, 1},                          // never below one worker
+		{0, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := autoWorkers(tt.cpus, tt.available); got != tt.want {
+			t.Errorf("autoWorkers(%d, %d) = %d, want %d", tt.cpus, tt.available, got, tt.want)
+		}
+	}
+}
+
+func TestParseConcurrency(t *testing.T) {
+	if n, ok := parseConcurrency(""); !ok || n != defaultWorkers {
+		t.Errorf(`parseConcurrency("") = %d, %v`, n, ok)
+	}
+	if n, ok := parseConcurrency("auto"); !ok || n < 1 {
+		t.Errorf(`parseConcurrency("auto") = %d, %v`, n, ok)
+	}
+	if _, ok := parseConcurrency("lots"); ok {
+		t.Error(`parseConcurrency("lots") accepted`)
+	}
+}
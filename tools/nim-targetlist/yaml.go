@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// yamlField is one key of a YAML mapping.
+type yamlField struct {
+	key   string
+	value reflect.Value
+}
+
+// yamlEncoder writes block-style YAML for the result types. Keys come from
+// the json struct tags, including omitempty, so the document mirrors the
+// JSON output key for key. Strings are always double-quoted, which keeps
+// values such as timestamps and "true"-like names from being retyped.
+type yamlEncoder struct {
+	b strings.Builder
+
+	// With anchors, repeated strings are written once as &name "value"
+	// and referred to as *name afterwards
+	repeated map[string]bool
+	anchors  map[string]string
+}
+
+// yamlAnchorMinLen is the shortest string worth replacing with an alias;
+// below it the *name reference saves next to nothing.
+const yamlAnchorMinLen = 8
+
+func (e *yamlEncoder) fields(v reflect.Value) []yamlField {
+	var fields []yamlField
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, e.fields(v.Field(i))...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(v.Field(i)) {
+			continue
+		}
+		fields = append(fields, yamlField{key: name, value: v.Field(i)})
+	}
+	return fields
+}
+
+func (e *yamlEncoder) mapEntries(v reflect.Value) []yamlField {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	entries := make([]yamlField, len(keys))
+	for i, key := range keys {
+		entries[i] = yamlField{key: key.String(), value: v.MapIndex(key)}
+	}
+	return entries
+}
+
+// isEmptyValue follows encoding/json's definition of empty for omitempty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// isBlock reports whether v is written as an indented block under its key
+// rather than inline after it.
+func isBlock(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Slice, reflect.Map:
+		return v.Len() > 0
+	}
+	return false
+}
+
+// scalar is yamlScalar, anchoring or aliasing repeated strings when the
+// encoder uses anchors.
+func (e *yamlEncoder) scalar(v reflect.Value) string {
+	if v.Kind() != reflect.String || !e.repeated[v.String()] {
+		return yamlScalar(v)
+	}
+	if name, ok := e.anchors[v.String()]; ok {
+		return "*" + name
+	}
+	name := fmt.Sprintf("a%d", len(e.anchors)+1)
+	e.anchors[v.String()] = name
+	return "&" + name + " " + yamlScalar(v)
+}
+
+// findRepeated marks the strings in v long and frequent enough to anchor.
+// Map keys are never anchored.
+func (e *yamlEncoder) findRepeated(v reflect.Value) {
+	counts := make(map[string]int)
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.String:
+			if len(v.String()) >= yamlAnchorMinLen {
+				counts[v.String()]++
+			}
+		case reflect.Struct:
+			for _, field := range e.fields(v) {
+				walk(field.value)
+			}
+		case reflect.Map:
+			for _, entry := range e.mapEntries(v) {
+				walk(entry.value)
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		}
+	}
+	walk(v)
+
+	e.repeated = make(map[string]bool)
+	e.anchors = make(map[string]string)
+	for s, n := range counts {
+		if n > 1 {
+			e.repeated[s] = true
+		}
+	}
+}
+
+func yamlScalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	case reflect.Slice:
+		if v.IsNil() {
+			return "null"
+		}
+		return "[]"
+	case reflect.Map:
+		if v.IsNil() {
+			return "null"
+		}
+		return "{}"
+	}
+	return "null"
+}
+
+// yamlPlainKey matches the keys that can be written unquoted.
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// yamlKey quotes map keys, such as a --wrap-key or a backend name, that
+// would not read back as the same plain string.
+func yamlKey(key string) string {
+	if yamlPlainKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// mapping writes fields at the given depth. As the first item of a list
+// entry, the first key shares the line with the "- " marker.
+func (e *yamlEncoder) mapping(fields []yamlField, depth int, listItem bool) {
+	if len(fields) == 0 && listItem {
+		e.b.WriteString(strings.Repeat("  ", depth-1) + "- {}\n")
+		return
+	}
+	for i, field := range fields {
+		pad := strings.Repeat("  ", depth)
+		if listItem && i == 0 {
+			pad = strings.Repeat("  ", depth-1) + "- "
+		}
+		key := yamlKey(field.key)
+		if isBlock(field.value) {
+			e.b.WriteString(pad + key + ":\n")
+			e.block(field.value, depth+1)
+		} else {
+			e.b.WriteString(pad + key + ": " + e.scalar(field.value) + "\n")
+		}
+	}
+}
+
+func (e *yamlEncoder) block(v reflect.Value, depth int) {
+	switch v.Kind() {
+	case reflect.Struct:
+		e.mapping(e.fields(v), depth, false)
+	case reflect.Map:
+		e.mapping(e.mapEntries(v), depth, false)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			if item.Kind() == reflect.Struct {
+				e.mapping(e.fields(item), depth+1, true)
+			} else {
+				e.b.WriteString(strings.Repeat("  ", depth) + "- " + e.scalar(item) + "\n")
+			}
+		}
+	}
+}
+
+// outputYAML writes the same document as outputJSON, as YAML, nested under
+// wrapKey if one is given. With anchors, every string of at least
+// yamlAnchorMinLen bytes that occurs more than once, such as sources or
+// runtime warnings, is written only the first time and aliased after that.
+func outputYAML(w io.Writer, targets []TargetInfo, scanner *TargetScanner, anchors bool, wrapKey string) error {
+	var e yamlEncoder
+	result := newTargetsResult(targets, scanner)
+	var doc interface{} = result
+	if wrapKey != "" {
+		doc = map[string]TargetsResult{wrapKey: result}
+	}
+	v := reflect.ValueOf(doc)
+	if anchors {
+		e.findRepeated(v)
+	}
+	e.b.WriteString("---\n")
+	e.block(v, 0)
+
+	_, err := io.WriteString(w, e.b.String())
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	yamlAnchorPattern    = regexp.MustCompile(`&(a\d+) ("(?:[^"\\]|\\.)*")`)
+	yamlAliasPattern     = regexp.MustCompile(`(?m)\*(a\d+)$`)
+	yamlGeneratedPattern = regexp.MustCompile(`(?m)^ *generated_at: .*$`)
+)
+
+// expandYAMLAliases resolves the anchors and aliases written by the
+// encoder, which only ever anchor scalars, back into plain values.
+func expandYAMLAliases(t *testing.T, doc string) string {
+	t.Helper()
+	values := make(map[string]string)
+	for _, m := range yamlAnchorPattern.FindAllStringSubmatch(doc, -1) {
+		if _, dup := values[m[1]]; dup {
+			t.Errorf("anchor %s defined twice", m[1])
+		}
+		values[m[1]] = m[2]
+	}
+	doc = yamlAnchorPattern.ReplaceAllString(doc, "$2")
+	return yamlAliasPattern.ReplaceAllStringFunc(doc, func(alias string) string {
+		value, ok := values[alias[1:]]
+		if !ok {
+			t.Errorf("alias %s has no anchor", alias)
+		}
+		return value
+	})
+}
+
+func TestOutputYAMLAnchors(t *testing.T) {
+	targets := []TargetInfo{
+		{OS: "linux", CPU: "avr", Source: "detected", RuntimeWarning: runtimeRequirements["avr"], VerifyStatus: verifyStatusVerified},
+		{OS: "standalone", CPU: "avr", Source: "detected", RuntimeWarning: runtimeRequirements["avr"], VerifyStatus: verifyStatusVerified},
+		{OS: "any", CPU: "avr", Source: "hardcoded", VerifyStatus: verifyStatusSkipped},
+	}
+	scanner := NewTargetScanner()
+
+	var plain, anchored bytes.Buffer
+	if err := outputYAML(&plain, targets, scanner, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := outputYAML(&anchored, targets, scanner, true, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := anchored.String()
+	for _, want := range []string{
+		`source: &a1 "detected"`,
+		`source: *a1`,
+		`runtime_warning: &a2 "requires avr-gcc and avr-libc"`,
+		`runtime_warning: *a2`,
+	} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("anchored YAML lacks %q:\n%s", want, doc)
+		}
+	}
+	// Strings that occur once, or are too short to gain anything, stay as is
+	for _, want := range []string{`source: "hardcoded"`, `cpu: "avr"`} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("anchored YAML lacks %q:\n%s", want, doc)
+		}
+	}
+	if anchored.Len() >= plain.Len() {
+		t.Errorf("anchored YAML is %d bytes, plain %d", anchored.Len(), plain.Len())
+	}
+
+	// Resolving the aliases gives back the plain document
+	got := yamlGeneratedPattern.ReplaceAllString(expandYAMLAliases(t, doc), "")
+	want := yamlGeneratedPattern.ReplaceAllString(plain.String(), "")
+	if got != want {
+		t.Errorf("anchored YAML does not expand to the plain YAML:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOutputYAMLWrapKey(t *testing.T) {
+	targets, scanner := sampleTargets()
+	var plain, wrapped bytes.Buffer
+	if err := outputYAML(&plain, targets, scanner, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := outputYAML(&wrapped, targets, scanner, false, "nim_targets"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The wrapped document is the plain one, indented under the key
+	var want strings.Builder
+	want.WriteString("---\nnim_targets:\n")
+	for _, line := range strings.SplitAfter(strings.TrimPrefix(plain.String(), "---\n"), "\n") {
+		if line != "" {
+			want.WriteString("  " + line)
+		}
+	}
+	got := yamlGeneratedPattern.ReplaceAllString(wrapped.String(), "")
+	if expected := yamlGeneratedPattern.ReplaceAllString(want.String(), ""); got != expected {
+		t.Errorf("wrapped YAML:\n%s\nwant:\n%s", got, expected)
+	}
+
+	wrapped.Reset()
+	if err := outputYAML(&wrapped, targets, scanner, false, "nim targets: all"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(wrapped.String(), "---\n\"nim targets: all\":\n") {
+		t.Errorf("wrap key not quoted:\n%s", wrapped.String())
+	}
+}